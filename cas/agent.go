@@ -1,6 +1,12 @@
 package cas
 
-var numAgents = 0
+import (
+    "math/rand"
+    "sync/atomic"
+)
+
+// Counter for the ids of Agents made outside of a Cohort:
+var numAgents int64 = 0
 
 type AgentMetadata struct {
     Id int
@@ -15,29 +21,56 @@ type Agent struct {
     id int
     // NOTE: More complex Agents might have multiple Classifiers.
     classifier *Classifier 
+    // Agents made by MakeStrategyAgent() play this instead:
+    strategy Strategy
     // NOTE: More complex Agents might have multiple resource types.
     resources int
+    // The per-bit mutation rate the Agent was made with (0 if it wasn't bred):
+    mutationRate float64
     /* NOTE: The Metadata, in this case, represents stuff which not only
        describes the Agent for reporting, but also might vary from experiment
        to experiment (beyond Prisoner's Dilemma).  */
     Metadata AgentMetadata
 }
 
+// Returns the decision depth of the Agent's Classifier (or 0 if it has none):
 func (a *Agent) Depth() int {
+    if a.classifier == nil {
+        return 0
+    }
     return a.classifier.Depth()
 }
 
-// Returns the classifier rule for this agent:
+// Returns the Agent's native Strategy, or nil if it uses a Classifier:
+func (a *Agent) Strategy() Strategy {
+    return a.strategy
+}
+
+/* Returns a short description of how the Agent plays: the name of its
+   Strategy, or its rule in the portable string format.  */
+func (a *Agent) Name() string {
+    if a.strategy != nil {
+        return a.strategy.Name()
+    }
+    return a.Encode()
+}
+
+// Returns the classifier rule for this agent (or nil if it has none):
 func (a *Agent) Rule() []int {
+    if a.classifier == nil {
+        return nil
+    }
     return a.classifier.Rule()
 }
 
-func (a *Agent) init(d int) {
-    a.id = numAgents
-    numAgents++
-    c := MakeClassifier(d)
-    a.classifier = &c
+func (a *Agent) init(c *Classifier) {
+    a.setId(int(atomic.AddInt64(&numAgents, 1) - 1))
+    a.classifier = c
     a.resources = 0
+}
+
+func (a *Agent) setId(n int) {
+    a.id = n
     a.Metadata = AgentMetadata{a.id, 0, 0, 0, 0, 0.0}
 }
 
@@ -46,23 +79,39 @@ func (a *Agent) CalcMove(s []int) int {
     return a.classifier.CalcMove(s)
 }
 
+// Calculates the Agent's move for a state given as an index (see History):
+func (a *Agent) CalcMoveIndex(i int) int {
+    return a.classifier.CalcMoveIndex(i)
+}
+
 /* Creates two new Agents with Classifier rules that are
    genetically crossed over reproductions of the parent
-   Classifiers.  */
-func (a *Agent) Combine(b *Agent, freq int) []Agent {
-    c, d := MakeAgent(a.Depth()), MakeAgent(a.Depth())
-    s := a.classifier.Combine(b.classifier, freq)
-    c.classifier = &s[0]
-    d.classifier = &s[1]
-    return []Agent{c, d}
+   Classifiers, each mutated at the rate m gives it for
+   generation g.  */
+func (a *Agent) Combine(b *Agent, x Crossover, m Mutator, g int, r *rand.Rand) []Agent {
+    s, t := a.classifier.cross(b.classifier, x, r)
+    p := []Agent{{}, {}}
+    for i, c := range []*Classifier{&s, &t} {
+        q := m.Rate(a, b, g, r)
+        c.mutate(q, r)
+        p[i].init(c)
+        p[i].mutationRate = q
+    }
+    return p
 }
 
-func MakeAgent(d int) Agent {
+// Makes an Agent with a random Classifier rule drawn from r:
+func MakeAgent(d int, r *rand.Rand) Agent {
     a := Agent{}
-    a.init(d)
+    c := MakeClassifier(d, r)
+    a.init(&c)
     return a
 }
 
+func (a *Agent) MutationRate() float64 {
+    return a.mutationRate
+}
+
 func (a *Agent) Id() int {
     return a.id
 }
@@ -1,13 +1,21 @@
 package cas
 
 import (
+    "math"
     "math/rand"
 
     "github.com/prisoners_dilemma/util"
 )
 
+/* The deepest rule a Classifier may have. A rule has 4^depth entries,
+   so this also keeps rules read from strings and files to a sane size.  */
+const MaxDepth = 8
+
+/* The rule is packed as a bitset: entry i of the rule is bit i % 64 of
+   word i / 64. Any bits past the end of the rule in the last word are
+   always kept at zero.  */
 type Classifier struct {
-    rule []int
+    rule []uint64
     depth int
 }
 
@@ -15,79 +23,129 @@ func (c *Classifier) Depth() int {
     return c.depth
 }
 
+// Returns the number of entries in the rule (2^(depth * 2)):
+func (c *Classifier) Len() int {
+    return util.Pow2Int(c.depth * 2)
+}
+
+// Returns the value of the rule at index i:
+func (c *Classifier) Bit(i int) int {
+    return int(c.rule[i / 64] >> uint(i % 64) & 1)
+}
+
+// Returns an unpacked copy of the rule, one int per entry:
 func (c *Classifier) Rule() []int {
-    return c.rule
+    r := make([]int, c.Len())
+    for i := range r {
+        r[i] = c.Bit(i)
+    }
+    return r
+}
+
+// Returns the packed rule as a string, for use as a map key:
+func (c *Classifier) key() string {
+    b := make([]byte, len(c.rule) * 8)
+    for i, w := range c.rule {
+        for j := 0; j < 8; j++ {
+            b[i * 8 + j] = byte(w >> uint(j * 8))
+        }
+    }
+    return string(b)
 }
 
 func (c *Classifier) init(d int) { 
     b := util.Pow2Int(d * 2)       
-    c.rule = make([]int, b)       
-    for i := 0; i < b; i++ {
-        c.rule[i] = rand.Intn(2)
-    }
+    c.rule = make([]uint64, (b + 63) / 64)
     c.depth = d
 }
 
-func MakeClassifier(d int) Classifier {
+// Returns a mask of the bits in use in the last word of the rule:
+func (c *Classifier) tailMask() uint64 {
+    n := c.Len() % 64
+    if n == 0 {
+        return ^uint64(0)
+    }
+    return uint64(1) << uint(n) - 1
+}
+
+// Makes a Classifier with a random rule drawn from r:
+func MakeClassifier(d int, r *rand.Rand) Classifier {
     c := Classifier{}
     c.init(d)
+    for i := range c.rule {
+        c.rule[i] = r.Uint64()
+    }
+    c.rule[len(c.rule) - 1] &= c.tailMask()
     return c
 }
 
-/* CalcMove iterates over the input slice of 1s and 0s as if it 
-   were computing the conversion of a number from base-2 to base-10. 
-   It doesn't really matter whether it reads the slice from left-to-
-   right or vice versa, as it leads to a unique mapping either way
-   as long as it is consistent. The slice's elements are converted 
-   to a base-10 index, and the the value of the Classifier Rule at 
-   that index is the response to the input.  */
-func (c *Classifier) CalcMove(s []int) int {
+/* Index treats the input slice of 1s and 0s as a binary number, with
+   the first element as the lowest bit. It doesn't really matter whether
+   it reads the slice from left-to-right or vice versa, as it leads to a
+   unique mapping either way as long as it is consistent.  */
+func (c *Classifier) Index(s []int) int {
     r := 0
     for i, v := range s {
         if v == 1 {
-            x := 1
-            for j := 0; j < i; j++ {
-                x *= 2
-            }
-            r += x
+            r |= 1 << uint(i)
         }
     }
-    return c.rule[r]
+    return r
 }
 
-/* As suggested in John Holland's paper, this combines two
-   Classifiers by performing "Genetic Crossover" on their
-   rules.  */
-func (c *Classifier) Combine(d *Classifier, freq int) []Classifier {
-    a, b := MakeClassifier(c.Depth()), MakeClassifier(c.Depth())
-
-    // A random pivot is chosen:
-    l := util.Pow2Int(c.Depth() * 2)
-    p := rand.Intn(l)
-    
-    // Points before the pivot are overlaid on to the 
-    // new Rules:
-    for i := 0; i < p; i++ {
-        a.rule[i] = d.rule[i]
-        b.rule[i] = c.rule[i]
-    }
-    // Points from the pivot onward are swapped
-    // from parent to offspring:
-    for i := p; i < l; i++ {
-        a.rule[i] = c.rule[i]
-        b.rule[i] = d.rule[i]
-    }
+/* CalcMove converts the input to an index (see Index()), and the value
+   of the Classifier Rule at that index is the response to the input.  */
+func (c *Classifier) CalcMove(s []int) int {
+    return c.Bit(c.Index(s))
+}
+
+/* CalcMoveIndex is CalcMove for a state which is already an index, such
+   as one from History.Index().  */
+func (c *Classifier) CalcMoveIndex(i int) int {
+    return c.Bit(i)
+}
+
+/* As suggested in John Holland's paper, this combines two Classifiers
+   by performing "Genetic Crossover" on their rules (see Crossover), and
+   then flipping each bit of the offspring with chance p.  */
+func (c *Classifier) Combine(d *Classifier, x Crossover, p float64, r *rand.Rand) []Classifier {
+    a, b := c.cross(d, x, r)
+
     // Mutation chance is applied:
-    f := func(n int) int { 
-        if rand.Intn(freq) == 0 {
-            return (n + 1) % 2 
-        }
-        return n
-    } 
-    for i := 0; i < l; i++ { 
-        a.rule[i] = f(a.rule[i])
-        b.rule[i] = f(b.rule[i])
-    }
+    a.mutate(p, r)
+    b.mutate(p, r)
     return []Classifier{a, b}
 }
 
+// Crosses c with d using x, keeping the offspring's tail bits clear:
+func (c *Classifier) cross(d *Classifier, x Crossover, r *rand.Rand) (Classifier, Classifier) {
+    a, b := x.Cross(c, d, r)
+    a.rule[len(a.rule) - 1] &= a.tailMask()
+    b.rule[len(b.rule) - 1] &= b.tailMask()
+    return a, b
+}
+
+/* Flips each bit of the rule with chance p. Rather than rolling for
+   every bit, the gap to the next flipped bit is drawn from the geometric
+   distribution, which comes out the same but only costs one roll per
+   flip.  */
+func (c *Classifier) mutate(p float64, r *rand.Rand) {
+    l := c.Len()
+    if p <= 0 {
+        return
+    }
+    if p >= 1 {
+        for w := range c.rule {
+            c.rule[w] = ^c.rule[w]
+        }
+        c.rule[len(c.rule) - 1] &= c.tailMask()
+        return
+    }
+    q := math.Log(1.0 - p)
+    skip := func() int {
+        return int(math.Log(1.0 - r.Float64()) / q)
+    }
+    for i := skip(); i >= 0 && i < l; i += 1 + skip() {
+        c.rule[i / 64] ^= uint64(1) << uint(i % 64)
+    }
+}
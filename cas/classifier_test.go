@@ -0,0 +1,65 @@
+package cas
+
+import (
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestClassifierCombine(t *testing.T) {
+    r := util.MakeRand(42)
+    for d := 1; d <= 4; d++ {
+        a, b := MakeClassifier(d, r), MakeClassifier(d, r)
+        if len(a.Rule()) != util.Pow2Int(d * 2) {
+            t.Fatalf("depth %d rule has %d entries", d, len(a.Rule()))
+        }
+        for i := 0; i < 100; i++ {
+            // With mutation effectively off, each offspring must take
+            // a prefix from one parent and the rest from the other:
+            s := a.Combine(&b, OnePointCrossover{}, 0, r)
+            x, y, u, v := s[0].Rule(), s[1].Rule(), a.Rule(), b.Rule()
+            p := 0
+            for p < len(x) && x[p] == v[p] && y[p] == u[p] {
+                p++
+            }
+            for j := p; j < len(x); j++ {
+                if x[j] != u[j] || y[j] != v[j] {
+                    t.Fatalf("depth %d offspring isn't a single-point crossover", d)
+                }
+            }
+        }
+        // With mutation on every bit, the offspring are inverted:
+        s := a.Combine(&a, OnePointCrossover{}, 1, r)
+        x, u := s[0].Rule(), a.Rule()
+        for j := range x {
+            if x[j] == u[j] {
+                t.Fatalf("depth %d bit %d not flipped", d, j)
+            }
+        }
+    }
+}
+
+func TestClassifierCalcMove(t *testing.T) {
+    r := util.MakeRand(7)
+    c := MakeClassifier(3, r)
+    x := c.Rule()
+    for i := range x {
+        s := make([]int, 6)
+        for j := range s {
+            s[j] = i >> uint(j) & 1
+        }
+        if c.CalcMove(s) != x[i] {
+            t.Fatalf("CalcMove disagrees with Rule() at index %d", i)
+        }
+    }
+}
+
+func TestClassifierCalcMoveIndex(t *testing.T) {
+    c := MakeClassifier(3, util.MakeRand(5))
+    x := c.Rule()
+    for i := range x {
+        if c.CalcMoveIndex(i) != x[i] {
+            t.Fatalf("CalcMoveIndex disagrees with Rule() at index %d", i)
+        }
+    }
+}
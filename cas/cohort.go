@@ -3,8 +3,6 @@ package cas
 import (
     "math/rand"
     "sort"
-
-    "github.com/prisoners_dilemma/lock"
 )
 
 type CohortMetadata struct { 
@@ -14,17 +12,17 @@ type CohortMetadata struct {
 } 
 
 /* The Cohort is mostly a slice of Agents, but it also
-   tracks metadata and handles concurrent generational
-   processing as well as post-generation evolution. It is
+   tracks metadata and handles post-generation evolution. It is
    the current highest level of the cas API, but soon I will
    have a few more levels so that Cohorts which are testing
    different parameters can be compared directly, and more.  */
 type Cohort struct {
     size int
     members []Agent
-    Lock lock.Lock
     generation int
     fitness float64
+    // Members are numbered by the Cohort so that ids are reproducible:
+    nextId int
     Metadata CohortMetadata
 }
 
@@ -40,24 +38,30 @@ func (c *Cohort) Generation() int {
     return c.generation
 }
 
-func (c *Cohort) init(n int, d int) {
+// Gives an Agent the next id in the Cohort's sequence:
+func (c *Cohort) enlist(a *Agent) {
+    a.setId(c.nextId)
+    c.nextId++
+}
+
+func (c *Cohort) init(n int, d int, r *rand.Rand) {
     m := n
     if m % 2 != 0 {
         m++
     }
     c.size = m
     c.members = make([]Agent, m)
-    c.Lock = lock.MakeLock(m)
     for i := 0; i < m; i++ {
-        c.members[i] = MakeAgent(d)
+        c.members[i] = MakeAgent(d, r)
+        c.enlist(&c.members[i])
     }
     c.generation = 0
     c.fitness = 0.0
 }
 
-func MakeCohort(n int, d int) Cohort {
+func MakeCohort(n int, d int, r *rand.Rand) Cohort {
     c := Cohort{}
-    c.init(n, d)
+    c.init(n, d, r)
     return c
 }
 
@@ -67,65 +71,46 @@ func (c *Cohort) SortByResources() {
     }) 
 }
 
-/* To evolve the Cohort, I followed the steps suggested by John Holland's
-   paper. The size of the generation never changes. The next generation is
-   first filled by the fit parents themselves, then by the offspring of fit 
-   parents, and then by however many of the rest can fit. The Cohort is
-   shuffled at the end of every Evolve() just to be safe.  */
-func (c *Cohort) Evolve(n int, g int, freq int) {
+/* To evolve the Cohort, the members are sorted in descending order by
+   resources, and a Selector fills the next generation from them (see
+   ThresholdSelector for the scheme suggested by John Holland's paper).
+   The size of the generation never changes. Offspring are bred with
+   Agent.Combine(), using the Crossover x and the Mutator m, and are
+   numbered and marked as being born in generation g. The Cohort is
+   shuffled at the end of every Evolve() just to be safe. All of the
+   randomness is drawn from r, so a seeded r gives a reproducible
+   result. Returns the number of Agents which reproduced.  */
+func (c *Cohort) Evolve(sel Selector, x Crossover, m Mutator, g int, r *rand.Rand) int {
 
     // Sort generation in descending order by resources
     c.SortByResources()
 
-    // Next generation:
-    s := make([]Agent, c.size, c.size)
-
-    r := 0
-    for ; r < c.size ; {
-        a := c.members[r]
-        if a.Resources() < n {
-            break
-        }
-        s[r] = a
-        s[r].TakeResources(n)
-        s[r].Metadata.Resources = s[r].Resources()
-        r++
-    }
-    // r is now the index in s after the last reproducing agent was
-    // inserted
-    
-    q, h := r - 1, r
-    if q % 2 != 0 {
-        q--
-    }
-    // q is now the index in s of the last reproducing agent
-    // h is now the index in c.members which is the next agent
-
-    for i := 0; i < q; i += 2 {
-        a, b := &s[i], &s[i + 1]
-        p := a.Combine(b, freq)
+    breed := func(a *Agent, b *Agent) []Agent {
+        p := a.Combine(b, x, m, g, r)
         for j := range p {
+            c.enlist(&p[j])
             p[j].Metadata.Generation = g
-            if r < c.size {
-                s[r] = p[j]
-                r++
-            }
         }
+        return p
     }
-    // r is now the index in s after the last inserted offspring
-
-    for ; r < c.size; r++ { 
-        s[r] = c.members[h]
-        h++
-    }
-    // s should now be full of the next generation
+    s, n := sel.Next(c.members, breed, r)
 
     c.members = s
-    rand.Shuffle(c.size, func(i, j int) {
+    r.Shuffle(c.size, func(i, j int) {
         c.members[i], c.members[j] = c.members[j], c.members[i]
     })
     c.Metadata = CohortMetadata{c.size, c.generation, c.fitness}
     c.generation++ 
+    return n
+}
+
+// Returns the number of distinct Classifier rules among the members:
+func (c *Cohort) Genotypes() int {
+    m := map[string]bool{}
+    for i := range c.members {
+        m[c.members[i].classifier.key()] = true
+    }
+    return len(m)
 }
 
 func (c *Cohort) Member(i int) *Agent {
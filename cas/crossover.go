@@ -0,0 +1,136 @@
+package cas
+
+import (
+    "fmt"
+    "math/rand"
+    "sort"
+)
+
+/* A Crossover recombines the rules of two parent Classifiers into the
+   rules of two offspring. Mutation is applied separately, afterwards.  */
+type Crossover interface {
+    Name() string
+    Cross(c *Classifier, d *Classifier, r *rand.Rand) (Classifier, Classifier)
+}
+
+/* Makes two offspring by swapping the bits of c and d wherever m(w), the
+   mask for word w, is set: the first offspring is c with those bits from
+   d, and the second is d with those bits from c.  */
+func crossWith(c *Classifier, d *Classifier, m func(w int) uint64) (Classifier, Classifier) {
+    a, b := Classifier{}, Classifier{}
+    a.init(c.Depth())
+    b.init(c.Depth())
+    for w := range a.rule {
+        x := m(w)
+        a.rule[w] = d.rule[w] & x | c.rule[w] &^ x
+        b.rule[w] = c.rule[w] & x | d.rule[w] &^ x
+    }
+    return a, b
+}
+
+// Returns the mask of the bits in word w whose index in the rule is p or more:
+func fromMask(p int, w int) uint64 {
+    if lo := w * 64; p <= lo {
+        return ^uint64(0)
+    } else if p < lo + 64 {
+        return ^(uint64(1) << uint(p - lo) - 1)
+    }
+    return 0
+}
+
+/* Single-point crossover, as suggested in John Holland's paper: a random
+   pivot is chosen, and the points before it are swapped between the
+   parents.  */
+type OnePointCrossover struct{}
+
+func (x OnePointCrossover) Name() string {
+    return "onepoint"
+}
+
+func (x OnePointCrossover) Cross(c *Classifier, d *Classifier, r *rand.Rand) (Classifier, Classifier) {
+    p := r.Intn(c.Len())
+    return crossWith(c, d, func(w int) uint64 {
+        return ^fromMask(p, w)
+    })
+}
+
+/* K-point crossover: K distinct cut points are chosen at random, and
+   every other segment between them is swapped between the parents.  */
+type KPointCrossover struct {
+    K int
+}
+
+func (x KPointCrossover) Name() string {
+    if x.K == 2 {
+        return "twopoint"
+    }
+    return fmt.Sprintf("kpoint:%d", x.K)
+}
+
+func (x KPointCrossover) Cross(c *Classifier, d *Classifier, r *rand.Rand) (Classifier, Classifier) {
+    l := c.Len()
+    k := x.K
+    if k > l - 1 {
+        k = l - 1
+    }
+    // Cut points are drawn from 1 to l - 1, so no segment is empty:
+    p := []int{}
+    seen := map[int]bool{}
+    for len(p) < k {
+        i := 1 + r.Intn(l - 1)
+        if !seen[i] {
+            seen[i] = true
+            p = append(p, i)
+        }
+    }
+    sort.Ints(p)
+    // A bit is swapped if an odd number of cuts come at or before it:
+    return crossWith(c, d, func(w int) uint64 {
+        m := uint64(0)
+        for _, i := range p {
+            m ^= fromMask(i, w)
+        }
+        return m
+    })
+}
+
+/* Uniform crossover: each bit is swapped between the parents on its own,
+   with probability P. The genome is a lookup table indexed by history, so
+   neighboring bits aren't related in any special way, and this doesn't
+   preserve runs of them the way the point crossovers do.  */
+type UniformCrossover struct {
+    P float64
+}
+
+func (x UniformCrossover) Name() string {
+    return fmt.Sprintf("uniform:%g", x.P)
+}
+
+func (x UniformCrossover) Cross(c *Classifier, d *Classifier, r *rand.Rand) (Classifier, Classifier) {
+    return crossWith(c, d, func(w int) uint64 {
+        // A fair coin for every bit is just a random word:
+        if x.P == 0.5 {
+            return r.Uint64()
+        }
+        m := uint64(0)
+        for i := 0; i < 64; i++ {
+            if r.Float64() < x.P {
+                m |= uint64(1) << uint(i)
+            }
+        }
+        return m
+    })
+}
+
+// No crossover at all: the offspring are clones of their parents.
+type CloneCrossover struct{}
+
+func (x CloneCrossover) Name() string {
+    return "none"
+}
+
+func (x CloneCrossover) Cross(c *Classifier, d *Classifier, r *rand.Rand) (Classifier, Classifier) {
+    return crossWith(c, d, func(w int) uint64 {
+        return 0
+    })
+}
@@ -0,0 +1,61 @@
+package cas
+
+import (
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+// Counts how many times the offspring switches between taking bits from u and from v:
+func switches(x []int, u []int, v []int) int {
+    n, from := 0, -1
+    for i := range x {
+        f := from
+        if u[i] != v[i] {
+            if x[i] == u[i] {
+                f = 0
+            } else {
+                f = 1
+            }
+        }
+        if from != -1 && f != from {
+            n++
+        }
+        from = f
+    }
+    return n
+}
+
+func TestCrossovers(t *testing.T) {
+    r := util.MakeRand(42)
+    for d := 1; d <= 4; d++ {
+        c, e := MakeClassifier(d, r), MakeClassifier(d, r)
+        u, v := c.Rule(), e.Rule()
+        cases := []struct {
+            x Crossover
+            max int
+        }{
+            {OnePointCrossover{}, 1},
+            {KPointCrossover{2}, 2},
+            {KPointCrossover{5}, 5},
+            {UniformCrossover{0.5}, len(u)},
+            {UniformCrossover{0.1}, len(u)},
+            {CloneCrossover{}, 0},
+        }
+        for _, k := range cases {
+            for i := 0; i < 50; i++ {
+                a, b := k.x.Cross(&c, &e, r)
+                x, y := a.Rule(), b.Rule()
+                for j := range x {
+                    // Every bit comes from one parent, and the offspring are complementary:
+                    if (x[j] != u[j] && x[j] != v[j]) || x[j] + y[j] != u[j] + v[j] {
+                        t.Fatalf("%s at depth %d made a bit from neither parent", k.x.Name(), d)
+                    }
+                }
+                if n := switches(x, u, v); n > k.max {
+                    t.Fatalf("%s at depth %d switched parents %d times", k.x.Name(), d, n)
+                }
+            }
+        }
+    }
+}
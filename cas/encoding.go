@@ -0,0 +1,111 @@
+package cas
+
+import (
+    "encoding/hex"
+    "fmt"
+    "hash/crc32"
+    "strconv"
+    "strings"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+/* Classifier rules can be written out as a portable string:
+
+       pdr1:<depth>:<bits>:<checksum>
+
+   <depth> is the decision depth in decimal. <bits> is the rule packed
+   eight entries to a byte, in hex, with entry 8k + j of the rule as bit j
+   (counting from the lowest) of byte k. <checksum> is the CRC-32 (IEEE)
+   of everything before the last colon, as eight hex digits. A depth 3
+   rule, for example, comes out as 16 hex digits of bits.  */
+const ruleEncodingPrefix = "pdr1"
+
+// Returns the rule in the portable string format:
+func (c *Classifier) Encode() string {
+    b := make([]byte, (c.Len() + 7) / 8)
+    for i := range b {
+        b[i] = byte(c.rule[i / 8] >> uint(i % 8 * 8))
+    }
+    s := fmt.Sprintf("%s:%d:%s", ruleEncodingPrefix, c.depth, hex.EncodeToString(b))
+    return fmt.Sprintf("%s:%08x", s, crc32.ChecksumIEEE([]byte(s)))
+}
+
+// Rebuilds a Classifier from the portable string format:
+func DecodeClassifier(s string) (Classifier, error) {
+    c := Classifier{}
+    s = strings.TrimSpace(s)
+    f := strings.Split(s, ":")
+    if len(f) != 4 || f[0] != ruleEncodingPrefix {
+        return c, fmt.Errorf("cas: %q is not a %s rule string", s, ruleEncodingPrefix)
+    }
+    x := s[:strings.LastIndex(s, ":")]
+    if fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(x))) != strings.ToLower(f[3]) {
+        return c, fmt.Errorf("cas: rule string checksum doesn't match")
+    }
+    d, err := strconv.Atoi(f[1])
+    if err != nil || d < 0 || d > MaxDepth {
+        return c, fmt.Errorf("cas: bad rule depth %q (must be from 0 to %d)", f[1], MaxDepth)
+    }
+    // The length is checked before anything is allocated for the rule:
+    if n := (util.Pow2Int(d * 2) + 7) / 8; len(f[2]) != n * 2 {
+        return c, fmt.Errorf("cas: %d hex digits is the wrong length for a depth %d rule (2^%d bits)", len(f[2]), d, d * 2)
+    }
+    b, err := hex.DecodeString(f[2])
+    if err != nil {
+        return c, fmt.Errorf("cas: bad rule bits: %v", err)
+    }
+    c.init(d)
+    for i := range b {
+        c.rule[i / 8] |= uint64(b[i]) << uint(i % 8 * 8)
+    }
+    if c.rule[len(c.rule) - 1] &^ c.tailMask() != 0 {
+        return c, fmt.Errorf("cas: depth %d rule has bits set past its end", d)
+    }
+    return c, nil
+}
+
+/* Makes a Classifier from an unpacked rule of 1s and 0s. Its length must
+   be 2^(depth * 2) for some depth.  */
+func MakeClassifierFromRule(rule []int) (Classifier, error) {
+    c := Classifier{}
+    d := 0
+    for util.Pow2Int(d * 2) < len(rule) {
+        d++
+    }
+    if util.Pow2Int(d * 2) != len(rule) {
+        return c, fmt.Errorf("cas: rule length %d isn't 2^(depth * 2) for any depth", len(rule))
+    }
+    if d > MaxDepth {
+        return c, fmt.Errorf("cas: depth %d rule is deeper than %d", d, MaxDepth)
+    }
+    c.init(d)
+    for i, v := range rule {
+        if v != 0 && v != 1 {
+            return c, fmt.Errorf("cas: rule entry %d is %d, not 0 or 1", i, v)
+        }
+        c.rule[i / 64] |= uint64(v) << uint(i % 64)
+    }
+    return c, nil
+}
+
+// Returns the Agent's rule in the portable string format:
+func (a *Agent) Encode() string {
+    return a.classifier.Encode()
+}
+
+// Makes a new Agent which uses the given Classifier:
+func MakeAgentFromClassifier(c Classifier) Agent {
+    a := Agent{}
+    a.init(&c)
+    return a
+}
+
+// Makes a new Agent from a rule in the portable string format:
+func DecodeAgent(s string) (Agent, error) {
+    c, err := DecodeClassifier(s)
+    if err != nil {
+        return Agent{}, err
+    }
+    return MakeAgentFromClassifier(c), nil
+}
@@ -0,0 +1,61 @@
+package cas
+
+import (
+    "fmt"
+    "hash/crc32"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestEncoding(t *testing.T) {
+    r := util.MakeRand(42)
+    for d := 0; d <= 5; d++ {
+        c := MakeClassifier(d, r)
+        s := c.Encode()
+        e, err := DecodeClassifier(s)
+        if err != nil {
+            t.Fatalf("depth %d: %v", d, err)
+        }
+        if !reflect.DeepEqual(c, e) {
+            t.Fatalf("depth %d rule changed after a round trip through %q", d, s)
+        }
+        f, err := MakeClassifierFromRule(c.Rule())
+        if err != nil || !reflect.DeepEqual(c, f) {
+            t.Fatalf("depth %d rule changed after a round trip through Rule()", d)
+        }
+    }
+    c := MakeClassifier(3, r)
+    if s := c.Encode(); len(strings.Split(s, ":")[2]) != 16 {
+        t.Fatalf("depth 3 rule should be 16 hex digits, got %q", s)
+    }
+    // Corruption must be caught by the checksum or the length check:
+    c = MakeClassifier(2, r)
+    s := c.Encode()
+    bad := []string{
+        strings.Replace(s, "pdr1:2:", "pdr1:3:", 1),
+        s[:9] + "0" + s[10:],
+        s[:len(s) - 1],
+        "pdr1:2:00:" + s[len(s) - 8:],
+    }
+    for _, b := range bad {
+        if b == s {
+            continue
+        }
+        if _, err := DecodeClassifier(b); err == nil {
+            t.Fatalf("corrupt rule string %q was accepted", b)
+        }
+    }
+    // Rules too deep, or too short for their depth, are refused even with a good checksum:
+    for _, x := range []string{"pdr1:9:" + strings.Repeat("00", 32768), "pdr1:16:00", "pdr1:3:00"} {
+        b := fmt.Sprintf("%s:%08x", x, crc32.ChecksumIEEE([]byte(x)))
+        if _, err := DecodeClassifier(b); err == nil {
+            t.Fatalf("rule string %.20q... was accepted", b)
+        }
+    }
+    if _, err := MakeClassifierFromRule(make([]int, 32)); err == nil {
+        t.Fatalf("rule of length 32 was accepted")
+    }
+}
@@ -0,0 +1,36 @@
+package cas
+
+/* A History holds the last depth moves of each of two players, packed
+   into an int per player with the oldest move in the lowest bit. Both
+   recording a move and finding the index of the state in a Classifier's
+   rule take a few shifts and masks, rather than building a slice of the
+   moves and converting it (see Index()). The players are numbered 0 and
+   1.  */
+type History struct {
+    depth int
+    moves [2]int
+}
+
+/* Makes a History of depth d. It starts full of zeros, which is the same
+   as if both players had cooperated for d rounds in a row.  */
+func MakeHistory(d int) History {
+    return History{depth: d}
+}
+
+func (h *History) Depth() int {
+    return h.depth
+}
+
+// Records move m (0 or 1) by player p, pushing out its oldest move:
+func (h *History) Record(p int, m int) {
+    h.moves[p] = h.moves[p] >> 1 | m << uint(h.depth - 1)
+}
+
+/* Returns the index of the state seen by a Classifier of depth d (which
+   must be no more than the History's), made of the last d moves of
+   player p followed by the last d moves of the other player. It is the
+   same index Index() gives for the slice of those moves, oldest first.  */
+func (h *History) Index(d int, p int) int {
+    s := uint(h.depth - d)
+    return h.moves[p] >> s | h.moves[1 - p] >> s << uint(d)
+}
@@ -0,0 +1,30 @@
+package cas
+
+import (
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestHistoryIndex(t *testing.T) {
+    r := util.MakeRand(11)
+    for depth := 1; depth <= 8; depth++ {
+        h := MakeHistory(depth)
+        // The same moves, kept the slow way, oldest first:
+        x := [][]int{make([]int, depth), make([]int, depth)}
+        for n := 0; n < 200; n++ {
+            p, m := r.Intn(2), r.Intn(2)
+            h.Record(p, m)
+            x[p] = append(x[p][1:], m)
+            for d := 1; d <= depth; d++ {
+                c := Classifier{}
+                for q := 0; q < 2; q++ {
+                    s := append(append([]int{}, x[q][depth - d:]...), x[1 - q][depth - d:]...)
+                    if h.Index(d, q) != c.Index(s) {
+                        t.Fatalf("depth %d history gives index %d for player %d at depth %d, want %d", depth, h.Index(d, q), q, d, c.Index(s))
+                    }
+                }
+            }
+        }
+    }
+}
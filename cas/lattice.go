@@ -0,0 +1,150 @@
+package cas
+
+import (
+    "math/rand"
+    "sort"
+)
+
+// Which cells count as the neighbors of a cell on a Lattice:
+type Neighborhood int
+
+const (
+    // The 4 cells which share an edge with it:
+    VON_NEUMANN Neighborhood = iota
+    // The 8 cells which share an edge or a corner with it:
+    MOORE
+)
+
+// How a cell on a Lattice is refilled by its neighbors:
+type Reproduction int
+
+const (
+    // With a mutated copy of its most successful neighbor:
+    IMITATE Reproduction = iota
+    // With an offspring of its two most successful neighbors:
+    COMBINE
+)
+
+/* A Lattice is a grid of Agents whose edges wrap around (a torus), so
+   that every cell has the same number of neighbors. Unlike a Cohort,
+   which is well mixed, an Agent on a Lattice only meets its neighbors,
+   and its place can only be taken by their offspring. Cells are numbered
+   row by row, from 0 to Size() - 1.  */
+type Lattice struct {
+    width int
+    height int
+    cells []Agent
+    generation int
+    // Cells are numbered by the Lattice so that ids are reproducible:
+    nextId int
+}
+
+func MakeLattice(w int, h int, d int, r *rand.Rand) Lattice {
+    l := Lattice{width: w, height: h}
+    l.cells = make([]Agent, w * h)
+    for i := range l.cells {
+        l.cells[i] = MakeAgent(d, r)
+        l.enlist(&l.cells[i])
+    }
+    return l
+}
+
+func (l *Lattice) enlist(a *Agent) {
+    a.setId(l.nextId)
+    l.nextId++
+}
+
+func (l *Lattice) Width() int {
+    return l.width
+}
+
+func (l *Lattice) Height() int {
+    return l.height
+}
+
+func (l *Lattice) Size() int {
+    return len(l.cells)
+}
+
+func (l *Lattice) Generation() int {
+    return l.generation
+}
+
+func (l *Lattice) Cell(i int) *Agent {
+    return &l.cells[i]
+}
+
+// Returns the cells next to cell i, wrapping around the edges:
+func (l *Lattice) Neighbors(i int, n Neighborhood) []int {
+    x, y := i % l.width, i / l.width
+    s := []int{}
+    for dy := -1; dy <= 1; dy++ {
+        for dx := -1; dx <= 1; dx++ {
+            if (dx == 0 && dy == 0) || (n == VON_NEUMANN && dx != 0 && dy != 0) {
+                continue
+            }
+            u, v := (x + dx + l.width) % l.width, (y + dy + l.height) % l.height
+            s = append(s, v * l.width + u)
+        }
+    }
+    return s
+}
+
+/* Decides what becomes of cell i, given a score for every cell (higher
+   is better). If no neighbor scored better than the cell itself, it
+   survives and false is returned. Otherwise an offspring of its best
+   neighbor (or its two best neighbors) is returned, made with the
+   Crossover x and the Mutator m, for the caller to Place() in the cell,
+   either at once or after every cell has been decided.  */
+func (l *Lattice) Offspring(i int, n Neighborhood, rp Reproduction, score []float64, x Crossover, m Mutator, r *rand.Rand) (Agent, bool) {
+    s := l.Neighbors(i, n)
+    sort.SliceStable(s, func(j, k int) bool {
+        return score[s[j]] > score[s[k]]
+    })
+    if score[s[0]] <= score[i] {
+        return Agent{}, false
+    }
+    a, b := &l.cells[s[0]], &l.cells[s[0]]
+    if rp == COMBINE {
+        b = &l.cells[s[1]]
+    } else {
+        x = CloneCrossover{}
+    }
+    return a.Combine(b, x, m, l.generation + 1, r)[0], true
+}
+
+// Puts an Agent made by Offspring() in cell i:
+func (l *Lattice) Place(i int, a Agent) {
+    l.enlist(&a)
+    a.Metadata.Generation = l.generation + 1
+    l.cells[i] = a
+}
+
+// Moves the Lattice on to its next generation:
+func (l *Lattice) Advance() {
+    l.generation++
+}
+
+/* Returns the most common Classifier rule on the Lattice, as the first
+   cell which holds it, and how many cells hold it.  */
+func (l *Lattice) Dominant() (*Agent, int) {
+    m := map[string]int{}
+    v, n := 0, 0
+    for i := range l.cells {
+        k := l.cells[i].classifier.key()
+        m[k]++
+        if m[k] > n {
+            v, n = i, m[k]
+        }
+    }
+    return &l.cells[v], n
+}
+
+// Returns the number of distinct Classifier rules on the Lattice:
+func (l *Lattice) Genotypes() int {
+    m := map[string]bool{}
+    for i := range l.cells {
+        m[l.cells[i].classifier.key()] = true
+    }
+    return len(m)
+}
@@ -0,0 +1,47 @@
+package cas
+
+import (
+    "sort"
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestLatticeNeighbors(t *testing.T) {
+    l := MakeLattice(5, 4, 2, util.MakeRand(42))
+    if len(l.Neighbors(7, VON_NEUMANN)) != 4 || len(l.Neighbors(7, MOORE)) != 8 {
+        t.Fatalf("wrong number of neighbors")
+    }
+    // The corner wraps around to the far edges:
+    s := l.Neighbors(0, MOORE)
+    sort.Ints(s)
+    want := []int{1, 4, 5, 6, 9, 15, 16, 19}
+    for i := range want {
+        if s[i] != want[i] {
+            t.Fatalf("neighbors of 0 are %v, expected %v", s, want)
+        }
+    }
+}
+
+func TestLatticeOffspring(t *testing.T) {
+    r := util.MakeRand(42)
+    l := MakeLattice(3, 3, 2, r)
+    score := make([]float64, l.Size())
+    // A cell at least as good as its neighbors survives:
+    score[4] = 1
+    if _, ok := l.Offspring(4, MOORE, IMITATE, score, OnePointCrossover{}, &FixedMutator{0}, r); ok {
+        t.Fatalf("best cell was replaced")
+    }
+    // Otherwise it imitates the best of them:
+    a, ok := l.Offspring(0, MOORE, IMITATE, score, OnePointCrossover{}, &FixedMutator{0}, r)
+    if !ok || a.classifier.key() != l.Cell(4).classifier.key() {
+        t.Fatalf("cell didn't imitate its best neighbor")
+    }
+    l.Place(0, a)
+    if a := l.Cell(0); a.Id() != 9 || a.Metadata.Generation != 1 {
+        t.Fatalf("placed Agent has id %d and generation %d", a.Id(), a.Metadata.Generation)
+    }
+    if _, n := l.Dominant(); n != 2 {
+        t.Fatalf("dominant rule is held by %d cells, expected 2", n)
+    }
+}
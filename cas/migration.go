@@ -0,0 +1,89 @@
+package cas
+
+import (
+    "math/rand"
+    "sort"
+)
+
+// Which islands send migrants to which in Migrate():
+type Topology int
+
+const (
+    // Each island receives from the one before it, and the first from the last:
+    RING Topology = iota
+    // Each island receives the best of all of the other islands' migrants:
+    FULLY_CONNECTED
+    // Each island receives from another island picked at random each time:
+    RANDOM_NEIGHBOR
+)
+
+// Returns copies of the m members with the most resources, best first:
+func (c *Cohort) Best(m int) []Agent {
+    c.SortByResources()
+    if m > c.size {
+        m = c.size
+    }
+    return append([]Agent{}, c.members[:m]...)
+}
+
+/* Replaces the members with the fewest resources by copies of the
+   Agents in a, which keep their resources but are numbered as members
+   of this Cohort.  */
+func (c *Cohort) Receive(a []Agent) {
+    c.SortByResources()
+    if len(a) > c.size {
+        a = a[:c.size]
+    }
+    for i := range a {
+        b := a[i]
+        g := b.Metadata.Generation
+        c.enlist(&b)
+        b.Metadata.Generation = g
+        c.members[c.size - len(a) + i] = b
+    }
+}
+
+func sortAgentsByResources(a []Agent) {
+    sort.SliceStable(a, func(i, j int) bool { // descending order
+        return a[i].Resources() > a[j].Resources()
+    })
+}
+
+/* Moves copies of the best m members of each island to another island
+   along the topology t, where they replace the worst m members. Every
+   island picks its migrants before any arrive, so the order of the
+   islands doesn't matter apart from the draws from r.  */
+func Migrate(c []*Cohort, m int, t Topology, r *rand.Rand) {
+    k := len(c)
+    if k < 2 || m < 1 {
+        return
+    }
+    out := make([][]Agent, k)
+    for i := range c {
+        out[i] = c[i].Best(m)
+    }
+    for i := range c {
+        var in []Agent
+        switch t {
+        case RING:
+            in = out[(i + k - 1) % k]
+        case FULLY_CONNECTED:
+            for j := range c {
+                if j != i {
+                    in = append(in, out[j]...)
+                }
+            }
+            sortAgentsByResources(in)
+            if len(in) > m {
+                in = in[:m]
+            }
+        case RANDOM_NEIGHBOR:
+            j := r.Intn(k - 1)
+            if j >= i {
+                j++
+            }
+            in = out[j]
+        }
+        c[i].Receive(in)
+    }
+}
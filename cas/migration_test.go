@@ -0,0 +1,61 @@
+package cas
+
+import (
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestMigrate(t *testing.T) {
+    for _, top := range []Topology{RING, FULLY_CONNECTED, RANDOM_NEIGHBOR} {
+        r := util.MakeRand(42)
+        c := make([]*Cohort, 4)
+        for i := range c {
+            x := MakeCohort(10, 2, r)
+            c[i] = &x
+            for j := 0; j < x.Size(); j++ {
+                // Island i's members have resources 100i + j:
+                x.Member(j).AddResources(100 * i + j)
+            }
+        }
+        Migrate(c, 2, top, r)
+        for i := range c {
+            if c[i].Size() != 10 {
+                t.Fatalf("topology %d changed the size of island %d", top, i)
+            }
+            ids, in := map[int]bool{}, 0
+            for j := 0; j < c[i].Size(); j++ {
+                a := c[i].Member(j)
+                if ids[a.Id()] {
+                    t.Fatalf("topology %d gave island %d two members with id %d", top, i, a.Id())
+                }
+                ids[a.Id()] = true
+                if a.Resources() / 100 != i {
+                    in++
+                    if a.Resources() % 100 < 8 {
+                        t.Fatalf("topology %d moved a migrant which wasn't among the best", top)
+                    }
+                }
+                // The worst 2 are always replaced:
+                if a.Resources() == 100 * i || a.Resources() == 100 * i + 1 {
+                    t.Fatalf("topology %d kept one of the worst members of island %d", top, i)
+                }
+            }
+            if in != 2 {
+                t.Fatalf("topology %d gave island %d %d migrants", top, i, in)
+            }
+        }
+        // Along a ring, island 1 hears from island 0:
+        if top == RING {
+            n := 0
+            for j := 0; j < c[1].Size(); j++ {
+                if x := c[1].Member(j).Resources(); x == 8 || x == 9 {
+                    n++
+                }
+            }
+            if n != 2 {
+                t.Fatalf("ring sent the wrong migrants to island 1")
+            }
+        }
+    }
+}
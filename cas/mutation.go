@@ -0,0 +1,151 @@
+package cas
+
+import (
+    "fmt"
+    "math"
+    "math/rand"
+)
+
+// The bounds on any adaptive per-bit mutation rate:
+const (
+    MIN_MUTATION_RATE = 1e-6
+    MAX_MUTATION_RATE = 0.5
+)
+
+/* A Mutator decides how likely each bit of an offspring's rule is to be
+   flipped after crossover. Cohort.Evolve() asks it for a rate for every
+   offspring, and the caller tells it the Cohort's fitness at the end of
+   every generation so that it can adapt. The rate used is kept by the
+   offspring (see Agent.MutationRate()). Mutators with state keep it in
+   exported fields so that they can be checkpointed as JSON.  */
+type Mutator interface {
+    Name() string
+    // Returns the per-bit rate for an offspring of a and b, born in generation g:
+    Rate(a *Agent, b *Agent, g int, r *rand.Rand) float64
+    // Is told the fitness of the Cohort at the end of generation g:
+    Observe(g int, fitness float64)
+}
+
+func clampRate(p float64) float64 {
+    if p < MIN_MUTATION_RATE {
+        return MIN_MUTATION_RATE
+    }
+    if p > MAX_MUTATION_RATE {
+        return MAX_MUTATION_RATE
+    }
+    return p
+}
+
+// Mutates every offspring at the same rate P, forever. The default:
+type FixedMutator struct {
+    P float64
+}
+
+func (m *FixedMutator) Name() string {
+    return fmt.Sprintf("fixed:%g", m.P)
+}
+
+func (m *FixedMutator) Rate(a *Agent, b *Agent, g int, r *rand.Rand) float64 {
+    return m.P
+}
+
+func (m *FixedMutator) Observe(g int, fitness float64) {}
+
+/* Moves the rate from From to To over the first Span generations, and
+   holds it at To after that. The rate falls in a straight line, or, if
+   Exponential is set, by the same factor every generation.  */
+type ScheduleMutator struct {
+    From float64
+    To float64
+    Span int
+    Exponential bool
+}
+
+func (m *ScheduleMutator) Name() string {
+    if m.Exponential {
+        return fmt.Sprintf("exponential:%g:%g", m.From, m.To)
+    }
+    return fmt.Sprintf("linear:%g:%g", m.From, m.To)
+}
+
+func (m *ScheduleMutator) Rate(a *Agent, b *Agent, g int, r *rand.Rand) float64 {
+    x := 1.0
+    if m.Span > 0 && g < m.Span {
+        x = float64(g) / float64(m.Span)
+    }
+    if m.Exponential {
+        return m.From * math.Pow(m.To / m.From, x)
+    }
+    return m.From + (m.To - m.From) * x
+}
+
+func (m *ScheduleMutator) Observe(g int, fitness float64) {}
+
+/* Rechenberg's 1/5th success rule. A generation is a success if the
+   Cohort's fitness went up on the last one. After every Window
+   generations, the rate P is divided by Factor if more than a fifth of
+   them were successes (the search is going well, so widen it), and
+   multiplied by Factor if fewer were (narrow it down).  */
+type SuccessRuleMutator struct {
+    P float64
+    Window int
+    Factor float64
+    // The state of the current window:
+    Last float64
+    Seen int
+    Successes int
+}
+
+func (m *SuccessRuleMutator) Name() string {
+    return fmt.Sprintf("fifth:%d", m.Window)
+}
+
+func (m *SuccessRuleMutator) Rate(a *Agent, b *Agent, g int, r *rand.Rand) float64 {
+    return m.P
+}
+
+func (m *SuccessRuleMutator) Observe(g int, fitness float64) {
+    if g > 0 && fitness > m.Last {
+        m.Successes++
+    }
+    m.Last = fitness
+    m.Seen++
+    if m.Seen < m.Window {
+        return
+    }
+    switch {
+    case m.Successes * 5 > m.Seen:
+        m.P = clampRate(m.P / m.Factor)
+    case m.Successes * 5 < m.Seen:
+        m.P = clampRate(m.P * m.Factor)
+    }
+    m.Seen, m.Successes = 0, 0
+}
+
+/* Self-adaptation, as in evolution strategies: every Agent carries its
+   own rate, and an offspring's rate is the geometric mean of its
+   parents' rates, scaled by a log-normal factor with spread Tau. The
+   offspring is then mutated at its new rate, so rates which lead to good
+   rules are passed on with them. Agents without a rate of their own
+   (such as the first generation) count as having the rate P.  */
+type SelfAdaptiveMutator struct {
+    P float64
+    Tau float64
+}
+
+func (m *SelfAdaptiveMutator) Name() string {
+    return fmt.Sprintf("self:%g", m.Tau)
+}
+
+func (m *SelfAdaptiveMutator) Rate(a *Agent, b *Agent, g int, r *rand.Rand) float64 {
+    p, q := a.MutationRate(), b.MutationRate()
+    if p <= 0 {
+        p = m.P
+    }
+    if q <= 0 {
+        q = m.P
+    }
+    return clampRate(math.Sqrt(p * q) * math.Exp(m.Tau * r.NormFloat64()))
+}
+
+func (m *SelfAdaptiveMutator) Observe(g int, fitness float64) {}
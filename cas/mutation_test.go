@@ -0,0 +1,75 @@
+package cas
+
+import (
+    "math"
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestScheduleMutator(t *testing.T) {
+    for _, e := range []bool{false, true} {
+        m := &ScheduleMutator{0.01, 0.0001, 10, e}
+        if p := m.Rate(nil, nil, 0, nil); p != 0.01 {
+            t.Fatalf("%s starts at %g", m.Name(), p)
+        }
+        if p := m.Rate(nil, nil, 10, nil); math.Abs(p - 0.0001) > 1e-12 {
+            t.Fatalf("%s ends at %g", m.Name(), p)
+        }
+        if p := m.Rate(nil, nil, 50, nil); math.Abs(p - 0.0001) > 1e-12 {
+            t.Fatalf("%s doesn't hold at %g", m.Name(), p)
+        }
+        for g := 1; g <= 10; g++ {
+            if m.Rate(nil, nil, g, nil) >= m.Rate(nil, nil, g - 1, nil) {
+                t.Fatalf("%s doesn't decay at generation %d", m.Name(), g)
+            }
+        }
+    }
+    // Halfway through, an exponential schedule is at the geometric mean:
+    m := &ScheduleMutator{0.01, 0.0001, 10, true}
+    if p := m.Rate(nil, nil, 5, nil); math.Abs(p - 0.001) > 1e-12 {
+        t.Fatalf("exponential schedule is at %g halfway", p)
+    }
+}
+
+func TestSuccessRuleMutator(t *testing.T) {
+    m := &SuccessRuleMutator{P: 0.01, Window: 5, Factor: 0.5}
+    // Improving every generation widens the search:
+    for g := 0; g < 5; g++ {
+        m.Observe(g, float64(g))
+    }
+    if m.P != 0.02 {
+        t.Fatalf("rate is %g after a window of successes", m.P)
+    }
+    // Never improving narrows it:
+    for g := 5; g < 15; g++ {
+        m.Observe(g, 0)
+    }
+    if m.P != 0.005 {
+        t.Fatalf("rate is %g after two windows of failures", m.P)
+    }
+}
+
+func TestSelfAdaptiveMutator(t *testing.T) {
+    r := util.MakeRand(42)
+    c := MakeCohort(40, 2, r)
+    m := &SelfAdaptiveMutator{0.01, 0.5}
+    for g := 1; g <= 20; g++ {
+        for i := 0; i < c.Size(); i++ {
+            c.Member(i).AddResources(r.Intn(10))
+        }
+        c.Evolve(TournamentSelector{3}, OnePointCrossover{}, m, g, r)
+    }
+    // Every member is an offspring with a rate of its own, and they differ:
+    seen := map[float64]bool{}
+    for i := 0; i < c.Size(); i++ {
+        p := c.Member(i).MutationRate()
+        if p < MIN_MUTATION_RATE || p > MAX_MUTATION_RATE {
+            t.Fatalf("member %d has rate %g", i, p)
+        }
+        seen[p] = true
+    }
+    if len(seen) < 2 {
+        t.Fatalf("mutation rates didn't vary")
+    }
+}
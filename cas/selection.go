@@ -0,0 +1,205 @@
+package cas
+
+import (
+    "fmt"
+    "math/rand"
+)
+
+/* A Selector decides which members of a Cohort reproduce, and fills the
+   next generation. Next() is given the members sorted in descending order
+   by resources, and a breed() function which combines two parents into
+   their offspring. It returns a next generation of the same size, and the
+   number of distinct members which reproduced.  */
+type Selector interface {
+    Name() string
+    Next(members []Agent, breed func(a *Agent, b *Agent) []Agent, r *rand.Rand) ([]Agent, int)
+}
+
+/* The scheme suggested by John Holland's paper, and the default. Members
+   with at least Threshold resources reproduce, paying Threshold resources
+   to do so, and are paired off in sorted order. The next generation is
+   first filled by the fit parents themselves, then by the offspring of
+   fit parents, and then by however many of the rest can fit.  */
+type ThresholdSelector struct {
+    Threshold int
+}
+
+func (sel ThresholdSelector) Name() string {
+    return fmt.Sprintf("threshold:%d", sel.Threshold)
+}
+
+func (sel ThresholdSelector) Next(members []Agent, breed func(a *Agent, b *Agent) []Agent, r *rand.Rand) ([]Agent, int) {
+    n, size := sel.Threshold, len(members)
+
+    // Next generation:
+    s := make([]Agent, size, size)
+
+    k := 0
+    for ; k < size ; {
+        a := members[k]
+        if a.Resources() < n {
+            break
+        }
+        s[k] = a
+        s[k].TakeResources(n)
+        s[k].Metadata.Resources = s[k].Resources()
+        k++
+    }
+    // k is now the index in s after the last reproducing agent was
+    // inserted
+    m := k
+    
+    q, h := k - 1, k
+    if q % 2 != 0 {
+        q--
+    }
+    // q is now the index in s of the last reproducing agent
+    // h is now the index in members which is the next agent
+
+    for i := 0; i < q && k < size; i += 2 {
+        p := breed(&s[i], &s[i + 1])
+        for j := range p {
+            if k < size {
+                s[k] = p[j]
+                k++
+            }
+        }
+    }
+    // k is now the index in s after the last inserted offspring
+
+    for ; k < size; k++ { 
+        s[k] = members[h]
+        h++
+    }
+    // s should now be full of the next generation
+    return s, m
+}
+
+/* Fills a whole new generation with offspring, choosing each parent by
+   calling pick(), which returns an index into members. Returns the new
+   generation and the number of distinct parents.  */
+func breedGeneration(members []Agent, 
+                     breed func(a *Agent, b *Agent) []Agent, 
+                     pick func() int) ([]Agent, int) {
+    s := make([]Agent, 0, len(members))
+    used := map[int]bool{}
+    for len(s) < len(members) {
+        i, j := pick(), pick()
+        used[i], used[j] = true, true
+        p := breed(&members[i], &members[j])
+        for k := range p {
+            if len(s) < len(members) {
+                s = append(s, p[k])
+            }
+        }
+    }
+    return s, len(used)
+}
+
+/* Tournament selection: each parent is the member with the most resources
+   out of Size members drawn at random (with replacement).  */
+type TournamentSelector struct {
+    Size int
+}
+
+func (sel TournamentSelector) Name() string {
+    return fmt.Sprintf("tournament:%d", sel.Size)
+}
+
+func (sel TournamentSelector) Next(members []Agent, breed func(a *Agent, b *Agent) []Agent, r *rand.Rand) ([]Agent, int) {
+    pick := func() int {
+        b := r.Intn(len(members))
+        for i := 1; i < sel.Size; i++ {
+            // Members are sorted, so a lower index is at least as fit:
+            if j := r.Intn(len(members)); j < b {
+                b = j
+            }
+        }
+        return b
+    }
+    return breedGeneration(members, breed, pick)
+}
+
+/* Fitness-proportionate (roulette wheel) selection: each parent is drawn
+   with a chance proportional to its resources. If nobody has any, every
+   member is equally likely.  */
+type RouletteSelector struct{}
+
+func (sel RouletteSelector) Name() string {
+    return "roulette"
+}
+
+func (sel RouletteSelector) Next(members []Agent, breed func(a *Agent, b *Agent) []Agent, r *rand.Rand) ([]Agent, int) {
+    w := make([]float64, len(members))
+    for i := range members {
+        w[i] = float64(members[i].Resources())
+    }
+    return breedGeneration(members, breed, wheel(w, r))
+}
+
+/* Linear rank selection: each parent is drawn with a chance proportional
+   to its rank, from n for the member with the most resources down to 1
+   for the member with the least.  */
+type RankSelector struct{}
+
+func (sel RankSelector) Name() string {
+    return "rank"
+}
+
+func (sel RankSelector) Next(members []Agent, breed func(a *Agent, b *Agent) []Agent, r *rand.Rand) ([]Agent, int) {
+    w := make([]float64, len(members))
+    for i := range members {
+        w[i] = float64(len(members) - i)
+    }
+    return breedGeneration(members, breed, wheel(w, r))
+}
+
+/* Truncation selection: parents are drawn uniformly from the best
+   Fraction of the members (by resources), and the rest don't reproduce.  */
+type TruncationSelector struct {
+    Fraction float64
+}
+
+func (sel TruncationSelector) Name() string {
+    return fmt.Sprintf("truncation:%g", sel.Fraction)
+}
+
+func (sel TruncationSelector) Next(members []Agent, breed func(a *Agent, b *Agent) []Agent, r *rand.Rand) ([]Agent, int) {
+    n := int(sel.Fraction * float64(len(members)))
+    if n < 1 {
+        n = 1
+    }
+    if n > len(members) {
+        n = len(members)
+    }
+    return breedGeneration(members, breed, func() int {
+        return r.Intn(n)
+    })
+}
+
+/* Returns a function which draws an index with a chance proportional to
+   its weight, or uniformly if all of the weights are zero.  */
+func wheel(w []float64, r *rand.Rand) func() int {
+    c := make([]float64, len(w))
+    t := 0.0
+    for i := range w {
+        t += w[i]
+        c[i] = t
+    }
+    return func() int {
+        if t <= 0 {
+            return r.Intn(len(w))
+        }
+        x := r.Float64() * t
+        lo, hi := 0, len(c) - 1
+        for lo < hi {
+            mid := (lo + hi) / 2
+            if c[mid] > x {
+                hi = mid
+            } else {
+                lo = mid + 1
+            }
+        }
+        return lo
+    }
+}
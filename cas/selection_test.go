@@ -0,0 +1,50 @@
+package cas
+
+import (
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestSelectors(t *testing.T) {
+    r := util.MakeRand(42)
+    sels := []Selector{
+        ThresholdSelector{5},
+        TournamentSelector{3},
+        RouletteSelector{},
+        RankSelector{},
+        TruncationSelector{0.2},
+    }
+    for _, sel := range sels {
+        c := MakeCohort(31, 2, r)
+        for i := 0; i < c.Size(); i++ {
+            c.Member(i).AddResources(i % 10)
+        }
+        ids := map[int]bool{}
+        for i := 0; i < c.Size(); i++ {
+            ids[c.Member(i).Id()] = true
+        }
+        m := c.Evolve(sel, OnePointCrossover{}, &FixedMutator{0.01}, 1, r)
+        if c.Size() != 32 || len(c.members) != 32 {
+            t.Fatalf("%s changed the size of the Cohort to %d", sel.Name(), len(c.members))
+        }
+        if m < 1 || m > c.Size() {
+            t.Fatalf("%s reported %d reproducers", sel.Name(), m)
+        }
+        for i := 0; i < c.Size(); i++ {
+            a := c.Member(i)
+            if !ids[a.Id()] && a.Metadata.Generation != 1 {
+                t.Fatalf("%s made offspring without marking its generation", sel.Name())
+            }
+            if ids[a.Id()] && a.Metadata.Generation != 0 {
+                t.Fatalf("%s changed the generation of a survivor", sel.Name())
+            }
+        }
+    }
+    // Truncation at 1/32 only ever breeds from the single fittest member:
+    c := MakeCohort(32, 2, r)
+    c.Member(5).AddResources(1)
+    if m := c.Evolve(TruncationSelector{1.0 / 32.0}, OnePointCrossover{}, &FixedMutator{0.01}, 1, r); m != 1 {
+        t.Fatalf("truncation bred from %d members, expected 1", m)
+    }
+}
@@ -0,0 +1,91 @@
+package cas
+
+import (
+    "encoding/json"
+    "fmt"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+/* Classifiers, Agents and Cohorts keep their fields unexported, so these
+   mirror types are what actually get written out to (and read back in
+   from) JSON, e.g. for checkpointing a run.  */
+
+type classifierJSON struct {
+    Depth int `json:"depth"`
+    Rule []uint64 `json:"rule"`
+}
+
+func (c *Classifier) MarshalJSON() ([]byte, error) {
+    return json.Marshal(classifierJSON{c.depth, c.rule})
+}
+
+func (c *Classifier) UnmarshalJSON(b []byte) error {
+    x := classifierJSON{}
+    if err := json.Unmarshal(b, &x); err != nil {
+        return err
+    }
+    // The depth is checked first, since the length of a deeper rule doesn't fit in an int:
+    if x.Depth < 0 || x.Depth > MaxDepth {
+        return fmt.Errorf("cas: bad rule depth %d (must be from 0 to %d)", x.Depth, MaxDepth)
+    }
+    if len(x.Rule) != (util.Pow2Int(x.Depth * 2) + 63) / 64 {
+        return fmt.Errorf("cas: %d words is the wrong length for a depth %d rule", len(x.Rule), x.Depth)
+    }
+    c.depth, c.rule = x.Depth, x.Rule
+    if c.rule[len(c.rule) - 1] &^ c.tailMask() != 0 {
+        return fmt.Errorf("cas: depth %d rule has bits set past its end", x.Depth)
+    }
+    return nil
+}
+
+type agentJSON struct {
+    Id int `json:"id"`
+    Classifier *Classifier `json:"classifier"`
+    Resources int `json:"resources"`
+    MutationRate float64 `json:"mutation_rate,omitempty"`
+    Metadata AgentMetadata `json:"metadata"`
+}
+
+func (a *Agent) MarshalJSON() ([]byte, error) {
+    return json.Marshal(agentJSON{a.id, a.classifier, a.resources, a.mutationRate, a.Metadata})
+}
+
+func (a *Agent) UnmarshalJSON(b []byte) error {
+    x := agentJSON{}
+    if err := json.Unmarshal(b, &x); err != nil {
+        return err
+    }
+    // Only Agents with Classifiers are written out, so one without is corrupt:
+    if x.Classifier == nil {
+        return fmt.Errorf("cas: Agent %d has no rule", x.Id)
+    }
+    a.id, a.classifier, a.resources, a.Metadata = x.Id, x.Classifier, x.Resources, x.Metadata
+    a.mutationRate = x.MutationRate
+    return nil
+}
+
+type cohortJSON struct {
+    Generation int `json:"generation"`
+    Fitness float64 `json:"fitness"`
+    NextId int `json:"next_id"`
+    Metadata CohortMetadata `json:"metadata"`
+    Members []Agent `json:"members"`
+}
+
+func (c *Cohort) MarshalJSON() ([]byte, error) {
+    return json.Marshal(cohortJSON{c.generation, c.fitness, c.nextId, c.Metadata, c.members})
+}
+
+func (c *Cohort) UnmarshalJSON(b []byte) error {
+    x := cohortJSON{}
+    if err := json.Unmarshal(b, &x); err != nil {
+        return err
+    }
+    if len(x.Members) == 0 {
+        return fmt.Errorf("cas: Cohort has no members")
+    }
+    c.size, c.members = len(x.Members), x.Members
+    c.generation, c.fitness, c.nextId, c.Metadata = x.Generation, x.Fitness, x.NextId, x.Metadata
+    return nil
+}
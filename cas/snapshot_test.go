@@ -0,0 +1,59 @@
+package cas
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestCohortJSON(t *testing.T) {
+    r := util.MakeRand(42)
+    c := MakeCohort(20, 2, r)
+    for i := 0; i < c.Size(); i++ {
+        c.Member(i).AddResources(r.Intn(10))
+    }
+    c.SetFitness(12.5)
+    c.Evolve(ThresholdSelector{5}, OnePointCrossover{}, &SelfAdaptiveMutator{0.01, 0.2}, 1, r)
+    b, err := json.Marshal(&c)
+    if err != nil {
+        t.Fatal(err)
+    }
+    d := Cohort{}
+    if err := json.Unmarshal(b, &d); err != nil {
+        t.Fatal(err)
+    }
+    if !reflect.DeepEqual(c, d) {
+        t.Fatalf("Cohort changed after a round trip through JSON")
+    }
+    // Both copies must keep evolving the same way:
+    x, y := util.MakeRand(7), util.MakeRand(7)
+    c.Evolve(ThresholdSelector{5}, OnePointCrossover{}, &FixedMutator{0.01}, 2, x)
+    d.Evolve(ThresholdSelector{5}, OnePointCrossover{}, &FixedMutator{0.01}, 2, y)
+    if !reflect.DeepEqual(c, d) {
+        t.Fatalf("restored Cohort evolved differently")
+    }
+}
+
+func TestClassifierJSONDepth(t *testing.T) {
+    for _, b := range []string{`{"depth":32,"rule":[]}`, `{"depth":9,"rule":[]}`, `{"depth":-1,"rule":[]}`, `{"depth":2,"rule":[]}`} {
+        c := Classifier{}
+        if err := json.Unmarshal([]byte(b), &c); err == nil {
+            t.Fatalf("corrupt rule %s was accepted", b)
+        }
+    }
+}
+
+func TestAgentJSONNoRule(t *testing.T) {
+    for _, b := range []string{`{"id":3,"classifier":null,"resources":0}`, `{"id":3,"resources":0}`} {
+        a := Agent{}
+        if err := json.Unmarshal([]byte(b), &a); err == nil {
+            t.Fatalf("Agent %s with no rule was accepted", b)
+        }
+    }
+    c := Cohort{}
+    if err := json.Unmarshal([]byte(`{"generation":1,"members":[{"id":0,"classifier":null}]}`), &c); err == nil {
+        t.Fatalf("Cohort with a member with no rule was accepted")
+    }
+}
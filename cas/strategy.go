@@ -0,0 +1,209 @@
+package cas
+
+import (
+    "fmt"
+    "math/rand"
+    "sort"
+)
+
+// Moves, as used by the classic strategies:
+const (
+    cooperate = 0
+    defect = 1
+)
+
+/* A Strategy is a natively implemented way of playing, as opposed to a
+   Classifier rule. It sees the whole history of the game so far from its
+   own point of view (its own moves and its opponent's, oldest first, both
+   empty on the first move), so it isn't limited to a decision depth and
+   can keep to a plan such as Grim Trigger's. Strategies may also be
+   stochastic, drawing from r.  */
+type Strategy interface {
+    Name() string
+    CalcMove(own []int, opp []int, r *rand.Rand) int
+}
+
+// Returns the opponent's last move, or cooperate on the first move:
+func lastMove(h []int) int {
+    if len(h) == 0 {
+        return cooperate
+    }
+    return h[len(h) - 1]
+}
+
+type titForTat struct{}
+
+func (s titForTat) Name() string { return "tft" }
+
+func (s titForTat) CalcMove(own []int, opp []int, r *rand.Rand) int {
+    return lastMove(opp)
+}
+
+// Tit-for-Tat, but only defects after two defections in a row:
+type titForTwoTats struct{}
+
+func (s titForTwoTats) Name() string { return "tf2t" }
+
+func (s titForTwoTats) CalcMove(own []int, opp []int, r *rand.Rand) int {
+    n := len(opp)
+    if n >= 2 && opp[n - 1] == defect && opp[n - 2] == defect {
+        return defect
+    }
+    return cooperate
+}
+
+// Cooperates until the opponent defects once, then defects forever:
+type grimTrigger struct{}
+
+func (s grimTrigger) Name() string { return "grim" }
+
+func (s grimTrigger) CalcMove(own []int, opp []int, r *rand.Rand) int {
+    for _, m := range opp {
+        if m == defect {
+            return defect
+        }
+    }
+    return cooperate
+}
+
+/* Win-Stay-Lose-Shift: repeats its last move if the opponent cooperated
+   (the "win" payoffs T and R), and switches if the opponent defected.  */
+type pavlov struct{}
+
+func (s pavlov) Name() string { return "pavlov" }
+
+func (s pavlov) CalcMove(own []int, opp []int, r *rand.Rand) int {
+    if lastMove(opp) == cooperate {
+        return lastMove(own)
+    }
+    return 1 - lastMove(own)
+}
+
+type alwaysCooperate struct{}
+
+func (s alwaysCooperate) Name() string { return "allc" }
+
+func (s alwaysCooperate) CalcMove(own []int, opp []int, r *rand.Rand) int {
+    return cooperate
+}
+
+type alwaysDefect struct{}
+
+func (s alwaysDefect) Name() string { return "alld" }
+
+func (s alwaysDefect) CalcMove(own []int, opp []int, r *rand.Rand) int {
+    return defect
+}
+
+// Tit-for-Tat, but defects on the first move:
+type suspiciousTitForTat struct{}
+
+func (s suspiciousTitForTat) Name() string { return "stft" }
+
+func (s suspiciousTitForTat) CalcMove(own []int, opp []int, r *rand.Rand) int {
+    if len(opp) == 0 {
+        return defect
+    }
+    return lastMove(opp)
+}
+
+/* Tit-for-Tat, but forgives a defection with a fixed probability. The
+   default of 1/3 is the most generous value which still can't be
+   exploited under Axelrod's payoffs (T=5, R=3, P=1, S=0).  */
+type generousTitForTat struct {
+    generosity float64
+}
+
+func (s generousTitForTat) Name() string { return "gtft" }
+
+func (s generousTitForTat) CalcMove(own []int, opp []int, r *rand.Rand) int {
+    if lastMove(opp) == defect && r.Float64() >= s.generosity {
+        return defect
+    }
+    return cooperate
+}
+
+var classicStrategies = map[string]Strategy {
+    "tft": titForTat{},
+    "tf2t": titForTwoTats{},
+    "grim": grimTrigger{},
+    "pavlov": pavlov{},
+    "allc": alwaysCooperate{},
+    "alld": alwaysDefect{},
+    "stft": suspiciousTitForTat{},
+    "gtft": generousTitForTat{1.0 / 3.0},
+}
+
+// Returns the names of the built-in classic strategies, in sorted order:
+func ClassicStrategies() []string {
+    n := []string{}
+    for k := range classicStrategies {
+        n = append(n, k)
+    }
+    sort.Strings(n)
+    return n
+}
+
+// Returns the built-in classic strategy with the given name:
+func ClassicStrategy(name string) (Strategy, error) {
+    s, ok := classicStrategies[name]
+    if !ok {
+        return nil, fmt.Errorf("cas: no classic strategy named %q (expected one of %v)", name, ClassicStrategies())
+    }
+    return s, nil
+}
+
+/* The classic strategies which only look back a few moves, with the
+   least depth of history each needs. These can also be played as a
+   Classifier rule (see ClassicClassifier()). The others need the whole
+   history (grim), the first move (stft) or randomness (gtft).  */
+var classicRuleDepths = map[string]int {
+    "tft": 1,
+    "tf2t": 2,
+    "pavlov": 1,
+    "allc": 1,
+    "alld": 1,
+}
+
+// Returns the least depth of a Classifier rule for a classic strategy, or 0 if it has none:
+func ClassicRuleDepth(name string) int {
+    return classicRuleDepths[name]
+}
+
+/* Makes the Classifier rule of depth d which plays a classic strategy.
+   Each entry is the Strategy's move given the histories its state stands
+   for: its own last d moves in the low bits of the index and its
+   opponent's in the high bits, oldest first, which is how the state is
+   laid out under the perspective encoding. A game's History starts as if
+   both players had cooperated, which is also how these strategies open,
+   so the rule plays just as the native Strategy does.  */
+func ClassicClassifier(name string, d int) (Classifier, error) {
+    c := Classifier{}
+    n := classicRuleDepths[name]
+    if n == 0 {
+        return c, fmt.Errorf("cas: classic strategy %q can't be written as a Classifier rule", name)
+    }
+    if d < n || d > MaxDepth {
+        return c, fmt.Errorf("cas: %s needs a depth from %d to %d, not %d", name, n, MaxDepth, d)
+    }
+    s := classicStrategies[name]
+    c.init(d)
+    own, opp := make([]int, d), make([]int, d)
+    for i := 0; i < c.Len(); i++ {
+        for j := 0; j < d; j++ {
+            own[j], opp[j] = i >> uint(j) & 1, i >> uint(d + j) & 1
+        }
+        c.rule[i / 64] |= uint64(s.CalcMove(own, opp, nil)) << uint(i % 64)
+    }
+    return c, nil
+}
+
+/* Makes an Agent which plays a native Strategy instead of a Classifier
+   rule. It has no Classifier, so it can't be combined with other Agents,
+   but it can take part in games like any other.  */
+func MakeStrategyAgent(s Strategy) Agent {
+    a := Agent{}
+    a.init(nil)
+    a.strategy = s
+    return a
+}
@@ -0,0 +1,79 @@
+package cas
+
+import (
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestClassicStrategies(t *testing.T) {
+    r := util.MakeRand(42)
+    // Each case is a strategy's own history, its opponent's, and the move it must make:
+    cases := []struct {
+        name string
+        own, opp []int
+        move int
+    }{
+        {"tft", []int{}, []int{}, cooperate},
+        {"tft", []int{0}, []int{1}, defect},
+        {"tf2t", []int{0, 0}, []int{0, 1}, cooperate},
+        {"tf2t", []int{0, 0}, []int{1, 1}, defect},
+        {"grim", []int{0, 1, 1}, []int{1, 0, 0}, defect},
+        {"pavlov", []int{1}, []int{0}, defect},
+        {"pavlov", []int{1}, []int{1}, cooperate},
+        {"pavlov", []int{0}, []int{1}, defect},
+        {"stft", []int{}, []int{}, defect},
+        {"stft", []int{1}, []int{0}, cooperate},
+        {"allc", []int{0}, []int{1}, cooperate},
+        {"alld", []int{1}, []int{0}, defect},
+        {"gtft", []int{0}, []int{0}, cooperate},
+    }
+    for _, x := range cases {
+        s, err := ClassicStrategy(x.name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if m := s.CalcMove(x.own, x.opp, r); m != x.move {
+            t.Fatalf("%s played %d against %v, expected %d", x.name, m, x.opp, x.move)
+        }
+    }
+    if _, err := ClassicStrategy("nope"); err == nil {
+        t.Fatalf("unknown strategy name was accepted")
+    }
+}
+
+func TestClassicClassifier(t *testing.T) {
+    r := util.MakeRand(8)
+    for _, name := range ClassicStrategies() {
+        n := ClassicRuleDepth(name)
+        if n == 0 {
+            if _, err := ClassicClassifier(name, 3); err == nil {
+                t.Fatalf("%s was written as a rule", name)
+            }
+            continue
+        }
+        if _, err := ClassicClassifier(name, n - 1); err == nil {
+            t.Fatalf("%s was written as a depth %d rule", name, n - 1)
+        }
+        s, _ := ClassicStrategy(name)
+        for d := n; d <= 4; d++ {
+            c, err := ClassicClassifier(name, d)
+            if err != nil {
+                t.Fatal(err)
+            }
+            // Any game so far, as the rule sees it: the last d moves, with cooperation before the start:
+            for k := 0; k < 200; k++ {
+                l := r.Intn(8)
+                own, opp := make([]int, l), make([]int, l)
+                for i := range own {
+                    own[i], opp[i] = r.Intn(2), r.Intn(2)
+                }
+                x, y := append(make([]int, d), own...), append(make([]int, d), opp...)
+                st := append(append([]int{}, x[len(x) - d:]...), y[len(y) - d:]...)
+                if m, want := c.CalcMove(st), s.CalcMove(own, opp, r); m != want {
+                    t.Fatalf("depth %d %s rule played %d after %v against %v, expected %d", d, name, m, own, opp, want)
+                }
+            }
+        }
+    }
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+
+    "github.com/prisoners_dilemma/cas"
+)
+
+/* A snapshot of a run of DiscoverPdRule() at the end of a generation.
+   Every PRNG stream in a run is derived from the seed and the generation
+   number, so the seed in Params and the generation of the Cohort are the
+   whole of the PRNG state, and a resumed run carries on exactly as the
+   original would have. The Mutator and the OpponentPool are saved too,
+   since they can carry state (such as an adaptive mutation rate or a hall
+   of fame) from generation to generation.  */
+type PdCheckpoint struct {
+    Params DiscoverPdRuleParams `json:"params"`
+    Cohort *cas.Cohort `json:"cohort"`
+    Mutator json.RawMessage `json:"mutator,omitempty"`
+    Opponents json.RawMessage `json:"opponents,omitempty"`
+    // What the run had cost so far, which a resumed run carries on from:
+    RoundsPlayed int64 `json:"rounds_played,omitempty"`
+    Seconds float64 `json:"seconds,omitempty"`
+}
+
+/* Writes a checkpoint to a file. It is written to a temporary file first
+   and then renamed over the old one, so a crash part way through can't
+   leave a half-written checkpoint behind.  */
+func SavePdCheckpoint(path string, ck PdCheckpoint) error {
+    b, err := json.Marshal(ck)
+    if err != nil {
+        return err
+    }
+    f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + ".tmp")
+    if err != nil {
+        return err
+    }
+    err = f.Chmod(0644)
+    if err == nil {
+        _, err = f.Write(b)
+    }
+    if err == nil {
+        err = f.Sync()
+    }
+    if cerr := f.Close(); err == nil {
+        err = cerr
+    }
+    if err == nil {
+        err = os.Rename(f.Name(), path)
+    }
+    if err != nil {
+        os.Remove(f.Name())
+    }
+    return err
+}
+
+func LoadPdCheckpoint(path string) (PdCheckpoint, error) {
+    ck := PdCheckpoint{}
+    b, err := os.ReadFile(path)
+    if err != nil {
+        return ck, err
+    }
+    err = json.Unmarshal(b, &ck)
+    // Checkpoints from before the game mode and encoding were parameters were alternating and joint:
+    if err == nil && ck.Params.GameMode == "" {
+        ck.Params.GameMode = "alternating"
+    }
+    if err == nil && ck.Params.Encoding == "" {
+        ck.Params.Encoding = "joint"
+    }
+    return ck, err
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "math"
+    "os"
+    "strings"
+    "time"
+)
+
+/* A mistake on the command line, as opposed to a run which failed. The
+   program exits with status 2 on one of these, and 1 on any other error.  */
+type pdUsageError struct {
+    command string
+    err error
+}
+
+func (e *pdUsageError) Error() string {
+    return e.err.Error()
+}
+
+func pdUsagef(command string, format string, a ...interface{}) error {
+    return &pdUsageError{command, fmt.Errorf(format, a...)}
+}
+
+/* The flags of a command, and their checks. Every flag a command might
+   have starts at its default, so a command which doesn't take a flag
+   still builds sensible parameters from it (see params()).  */
+type pdFlags struct {
+    fs *flag.FlagSet
+    arguments string
+    checks []func() error
+
+    seed int64
+    notifications bool
+    decisionDepth int
+    cohortSize int
+    numRounds int
+    rThreshold int
+    genCap int
+    fitGoal int
+    mutationFrequency int
+    controlSampleSize int
+    gamesPerGen int
+    classicGames int
+    scoring int
+    payoff PdPayoff
+    allowInvalidPayoff bool
+    actionNoise float64
+    perceptionNoise float64
+    gameMode string
+    encoding string
+    mutation string
+    opponents string
+    selection string
+    crossover string
+    statsFile string
+    statsFormat string
+    checkpointFile string
+    checkpointEvery int
+    resume string
+    exportRule string
+    png string
+    dot string
+    tournamentReps int
+    selfPlay bool
+    crossCheck bool
+    neighborhood string
+    reproduction string
+    update string
+    topology string
+    migrants int
+    migrationInterval int
+    tunePopulation int
+    tuneSeeds int
+    tuneCost float64
+    tuneCostUnit string
+    tuneOut string
+}
+
+/* Makes the flags of a command. The usage line names its arguments,
+   and the summary says what it does, for its -help text.  */
+func makePdFlags(command string, arguments string, summary string) *pdFlags {
+    f := &pdFlags{
+        seed: USE_SYSTEM_TIME,
+        decisionDepth: DECISION_DEPTH,
+        cohortSize: COHORT_SIZE,
+        numRounds: NUM_ROUNDS,
+        rThreshold: REPRODUCTION_THRESHOLD,
+        genCap: GENERATION_CAP,
+        fitGoal: FITNESS_GOAL,
+        mutationFrequency: MUTATION_FREQUENCY,
+        controlSampleSize: RANDOM_SAMPLE_SIZE,
+        gamesPerGen: GAMES_PER_GENERATION,
+        classicGames: CLASSIC_GAMES,
+        scoring: SCORE_YEARS,
+        gameMode: "simultaneous",
+        encoding: "perspective",
+        mutation: "fixed",
+        opponents: "random",
+        selection: "threshold",
+        crossover: "onepoint",
+        statsFormat: "csv",
+        tournamentReps: TOURNAMENT_REPS,
+        selfPlay: true,
+        neighborhood: "moore",
+        reproduction: "imitate",
+        update: "sync",
+        topology: "ring",
+        migrants: ISLAND_MIGRANTS,
+        migrationInterval: ISLAND_INTERVAL,
+        tunePopulation: TUNE_POPULATION,
+        tuneSeeds: TUNE_SEEDS,
+        tuneCost: TUNE_COST,
+        tuneCostUnit: "rounds",
+    }
+    f.arguments = arguments
+    f.fs = flag.NewFlagSet(command, flag.ContinueOnError)
+    f.fs.Usage = func() {
+        w := f.fs.Output()
+        fmt.Fprintf(w, "Usage: prisoners_dilemma %s [flags] %s\n\n%s\n\nFlags:\n", command, arguments, summary)
+        f.fs.PrintDefaults()
+    }
+    return f
+}
+
+// Registers an int flag which must be from lo to hi (math.MaxInt32 for no upper bound):
+func (f *pdFlags) intVar(v *int, name string, lo int, hi int, usage string) {
+    f.fs.IntVar(v, name, *v, usage + " (" + pdRangeText(float64(lo), float64(hi)) + ")")
+    f.checks = append(f.checks, func() error {
+        if *v < lo || *v > hi {
+            return fmt.Errorf("-%s=%d is out of range: it must be %s", name, *v, pdRangeText(float64(lo), float64(hi)))
+        }
+        return nil
+    })
+}
+
+func (f *pdFlags) floatVar(v *float64, name string, lo float64, hi float64, usage string) {
+    f.fs.Float64Var(v, name, *v, usage + " (" + pdRangeText(lo, hi) + ")")
+    f.checks = append(f.checks, func() error {
+        if *v < lo || *v > hi || math.IsNaN(*v) {
+            return fmt.Errorf("-%s=%g is out of range: it must be %s", name, *v, pdRangeText(lo, hi))
+        }
+        return nil
+    })
+}
+
+// Registers a string flag which must be one of a fixed set of choices:
+func (f *pdFlags) choiceVar(v *string, name string, choices []string, usage string) {
+    f.fs.StringVar(v, name, *v, usage + " (" + strings.Join(choices, ", ") + ")")
+    f.checks = append(f.checks, func() error {
+        for _, c := range choices {
+            if *v == c {
+                return nil
+            }
+        }
+        return fmt.Errorf("-%s=%q is not one of %s", name, *v, strings.Join(choices, ", "))
+    })
+}
+
+func pdRangeText(lo float64, hi float64) string {
+    if hi >= math.MaxInt32 {
+        return fmt.Sprintf("at least %g", lo)
+    }
+    return fmt.Sprintf("from %g to %g", lo, hi)
+}
+
+// The flags which decide how each game is played:
+func (f *pdFlags) game() {
+    f.fs.Int64Var(&f.seed, "seed", f.seed, "seed for the whole run, for reproducibility (-1 for the system time)")
+    f.intVar(&f.numRounds, "numRounds", 1, math.MaxInt32, "rounds in each game")
+    f.intVar(&f.scoring, "scoring", SCORE_YEARS, SCORE_POINTS, "scoring convention: 0 for years in prison (lower is better), 1 for points (higher is better)")
+    f.fs.IntVar(&f.payoff.Temptation, "temptation", 0, "payoff T for defecting against a cooperator (default depends on -scoring)")
+    f.fs.IntVar(&f.payoff.Reward, "reward", 0, "payoff R for mutual cooperation (default depends on -scoring)")
+    f.fs.IntVar(&f.payoff.Punishment, "punishment", 0, "payoff P for mutual defection (default depends on -scoring)")
+    f.fs.IntVar(&f.payoff.Suckers, "suckers", 0, "payoff S for cooperating against a defector (default depends on -scoring)")
+    f.fs.BoolVar(&f.allowInvalidPayoff, "allowInvalidPayoff", false, "run with payoffs which don't make a Prisoner's Dilemma anyway")
+    f.floatVar(&f.actionNoise, "actionNoise", 0, 1, "chance that a move comes out as the opposite of the one intended")
+    f.floatVar(&f.perceptionNoise, "perceptionNoise", 0, 1, "chance that a move is seen wrongly by the opponent")
+    f.choiceVar(&f.gameMode, "gameMode", []string{"simultaneous", "alternating"}, "whether both players move at once each round, or one after the other")
+    f.choiceVar(&f.encoding, "encoding", []string{"perspective", "joint"}, "whether a rule sees its own moves first from either seat, or the first seat's moves first")
+}
+
+// The depth of the rules played, or of random rules made:
+func (f *pdFlags) depth() {
+    f.intVar(&f.decisionDepth, "decisionDepth", 1, DEPTH_CAP, "rounds of history each rule looks back on")
+}
+
+// The flags which test a rule once it has been found or loaded:
+func (f *pdFlags) testing() {
+    f.intVar(&f.controlSampleSize, "controlSampleSize", 1, math.MaxInt32, "random Agents the rule is tested against, for the games not worked out exactly")
+    f.intVar(&f.classicGames, "classicGames", 0, math.MaxInt32, "games against each classic strategy after testing (0 to skip)")
+}
+
+// The flags which shape the evolution of a Cohort:
+func (f *pdFlags) evolution() {
+    f.depth()
+    f.fs.BoolVar(&f.notifications, "notifications", false, "print the progress of the run")
+    f.intVar(&f.cohortSize, "cohortSize", 2, math.MaxInt32, "Agents in the Cohort")
+    f.intVar(&f.rThreshold, "rThreshold", 0, math.MaxInt32, "resources an Agent needs to reproduce")
+    f.intVar(&f.genCap, "genCap", 1, math.MaxInt32, "most generations to run for")
+    f.intVar(&f.fitGoal, "fitGoal", 0, 100, "Cohort fitness, in percent, at which the run stops")
+    f.intVar(&f.mutationFrequency, "mutationFrequency", 1, math.MaxInt32, "each bit of a new rule flips with a chance of 1 in this many")
+    f.intVar(&f.gamesPerGen, "gamesPerGen", 1, math.MaxInt32, "games each Agent plays each generation")
+    f.fs.StringVar(&f.mutation, "mutation", f.mutation, "mutation mode: fixed, linear[:from[:to]], exponential[:from[:to]], fifth[:window] or self[:tau]")
+    f.fs.StringVar(&f.selection, "selection", f.selection, "selection scheme: threshold, tournament[:k], roulette, rank or truncation[:fraction]")
+    f.fs.StringVar(&f.crossover, "crossover", f.crossover, "crossover operator: onepoint, twopoint, kpoint:<k>, uniform[:p] or none")
+    f.fs.StringVar(&f.opponents, "opponents", f.opponents, "opponent pool: random, cohort, hall[:n], classics or mix:<pool>=<weight>,...")
+}
+
+// The flags which record every generation, for the commands which open them (see openStats()):
+func (f *pdFlags) stats() {
+    f.fs.StringVar(&f.statsFile, "statsFile", "", "write a record of every generation to this file")
+    f.choiceVar(&f.statsFormat, "statsFormat", []string{"csv", "jsonl"}, "format of -statsFile")
+}
+
+/* Parses the command line of a command, which must have n positional
+   arguments, and checks every flag. Flags may come before, between or
+   after the positional arguments. Mistakes are returned rather than
+   printed, and only -help prints the usage.  */
+func (f *pdFlags) parse(args []string, n int) ([]string, error) {
+    var pos []string
+    f.fs.SetOutput(io.Discard)
+    for {
+        if err := f.fs.Parse(args); err != nil {
+            if err == flag.ErrHelp {
+                f.fs.SetOutput(os.Stderr)
+                f.fs.Usage()
+                return nil, err
+            }
+            return nil, &pdUsageError{f.fs.Name(), err}
+        }
+        if f.fs.NArg() == 0 {
+            break
+        }
+        pos = append(pos, f.fs.Arg(0))
+        args = f.fs.Args()[1:]
+    }
+    if len(pos) != n {
+        if n == 0 {
+            return nil, pdUsagef(f.fs.Name(), "%s takes no arguments, got %q", f.fs.Name(), strings.Join(pos, " "))
+        }
+        return nil, pdUsagef(f.fs.Name(), "%s takes %s, got %d argument(s)", f.fs.Name(), f.arguments, len(pos))
+    }
+    for _, check := range f.checks {
+        if err := check(); err != nil {
+            return nil, &pdUsageError{f.fs.Name(), err}
+        }
+    }
+    return pos, nil
+}
+
+// Returns whether a flag was given on the command line:
+func (f *pdFlags) given(name string) bool {
+    found := false
+    f.fs.Visit(func(x *flag.Flag) {
+        if x.Name == name {
+            found = true
+        }
+    })
+    return found
+}
+
+/* Returns the parameters of a run from the flags. Payoffs which aren't
+   given default to those of the scoring convention.  */
+func (f *pdFlags) params() DiscoverPdRuleParams {
+    seed := f.seed
+    if seed == USE_SYSTEM_TIME {
+        seed = time.Now().UnixNano()
+    }
+    payoff := DefaultPdPayoff(f.scoring)
+    if f.given("temptation") {
+        payoff.Temptation = f.payoff.Temptation
+    }
+    if f.given("reward") {
+        payoff.Reward = f.payoff.Reward
+    }
+    if f.given("punishment") {
+        payoff.Punishment = f.payoff.Punishment
+    }
+    if f.given("suckers") {
+        payoff.Suckers = f.payoff.Suckers
+    }
+    return DiscoverPdRuleParams{
+        Seed: seed,
+        CohortSize: f.cohortSize,
+        Squelch: !f.notifications,
+        NumRounds: f.numRounds,
+        DecisionDepth: f.decisionDepth,
+        ResourceThreshold: f.rThreshold,
+        GenerationCap: f.genCap,
+        FitnessGoal: f.fitGoal,
+        MutationFrequency: f.mutationFrequency,
+        Mutation: f.mutation,
+        Selection: f.selection,
+        Crossover: f.crossover,
+        ControlSampleSize: f.controlSampleSize,
+        GamesPerGen: f.gamesPerGen,
+        Opponents: f.opponents,
+        ClassicGames: f.classicGames,
+        Payoff: payoff,
+        ActionNoise: f.actionNoise,
+        PerceptionNoise: f.perceptionNoise,
+        GameMode: f.gameMode,
+        Encoding: f.encoding,
+        AllowInvalidPayoff: f.allowInvalidPayoff,
+        CheckpointFile: f.checkpointFile,
+        CheckpointEvery: f.checkpointEvery,
+    }
+}
+
+/* Opens the -statsFile, if one was given, and sets it as the stats
+   writer of p. A resumed run adds to the file rather than starting it
+   again. The returned function closes it.  */
+func (f *pdFlags) openStats(p *DiscoverPdRuleParams) (func(), error) {
+    if f.statsFile == "" {
+        return func() {}, nil
+    }
+    mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+    if f.resume != "" {
+        mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+    }
+    w, err := os.OpenFile(f.statsFile, mode, 0644)
+    if err != nil {
+        return nil, err
+    }
+    makeWriter := MakeStatsWriter
+    if fi, err := w.Stat(); err == nil && fi.Size() > 0 {
+        makeWriter = MakeStatsAppender
+    }
+    p.Stats, err = makeWriter(w, f.statsFormat)
+    if err != nil {
+        w.Close()
+        return nil, err
+    }
+    return func() { w.Close() }, nil
+}
+
+// Prints an error, and the way to get help if it was a usage error, and returns the exit status:
+func pdExitStatus(w io.Writer, err error) int {
+    if err == nil || errors.Is(err, flag.ErrHelp) {
+        return 0
+    }
+    fmt.Fprintln(w, "Error:", err)
+    var u *pdUsageError
+    if errors.As(err, &u) {
+        if u.command != "" {
+            fmt.Fprintf(w, "Run 'prisoners_dilemma %s -help' for usage.\n", u.command)
+        } else {
+            fmt.Fprintln(w, "Run 'prisoners_dilemma help' for usage.")
+        }
+        return 2
+    }
+    return 1
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+// Every mistake on the command line is a usage error, and asking for help isn't an error at all:
+func TestPdExitStatus(t *testing.T) {
+    tests := []struct {
+        args []string
+        status int
+        // Part of what's written on an error:
+        message string
+    }{
+        {[]string{"discover", "-nope"}, 2, "prisoners_dilemma discover -help"},
+        {[]string{"-nope"}, 2, "flag provided but not defined"},
+        {[]string{"discover", "-cohortSize=1"}, 2, "-cohortSize=1 is out of range"},
+        {[]string{"discover", "-decisionDepth=99"}, 2, "-decisionDepth=99 is out of range"},
+        {[]string{"discover", "-selection=bogus"}, 2, "bogus"},
+        {[]string{"discover", "-selection=tournament:x"}, 2, "prisoners_dilemma discover -help"},
+        {[]string{"discover", "extra"}, 2, "discover takes no arguments"},
+        {[]string{"evaluate"}, 2, "got 0 argument(s)"},
+        {[]string{"evaluate", "a", "b"}, 2, "got 2 argument(s)"},
+        {[]string{"bogus"}, 2, "prisoners_dilemma help"},
+        // Only the commands which write stats take the stats flags:
+        {[]string{"tune", "1", "-statsFile=stats.csv"}, 2, "prisoners_dilemma tune -help"},
+        {[]string{"serve", "localhost:0", "-statsFormat=jsonl"}, 2, "prisoners_dilemma serve -help"},
+        {[]string{"serve", "localhost:0", "-cohortSize=20000"}, 2, "more than the 10000 the server takes on"},
+        {[]string{"discover", "-help"}, 0, ""},
+        {[]string{"evaluate", "-h"}, 0, ""},
+        {[]string{"help", "discover"}, 0, ""},
+    }
+    for _, test := range tests {
+        var w bytes.Buffer
+        status := pdExitStatus(&w, runPdCommand(test.args))
+        if status != test.status {
+            t.Errorf("%q exits with %d, want %d: %s", test.args, status, test.status, w.String())
+            continue
+        }
+        if !strings.Contains(w.String(), test.message) {
+            t.Errorf("%q writes %q, want it to mention %q", test.args, w.String(), test.message)
+        }
+    }
+}
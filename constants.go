@@ -1,5 +1,9 @@
 package main
 
+import (
+    "github.com/prisoners_dilemma/cas"
+)
+
 const (
     DECISION_DEPTH = 3 
     COHORT_SIZE = 300
@@ -10,9 +14,32 @@ const (
     GAMES_PER_GENERATION = 10 
     RANDOM_SAMPLE_SIZE = 1000000 
     MUTATION_FREQUENCY = 10000
+    CLASSIC_GAMES = 100
+    TOURNAMENT_REPS = 10
+    SELECTION_TOURNAMENT_SIZE = 3
+    SELECTION_TRUNCATION = 0.5
+    MUTATION_SCHEDULE_SPREAD = 10.0
+    MUTATION_SUCCESS_WINDOW = 10
+    MUTATION_SUCCESS_FACTOR = 0.85
+    MUTATION_SELF_TAU = 0.2
+    HALL_OF_FAME_SIZE = 50
+    ISLAND_MIGRANTS = 2
+    ISLAND_INTERVAL = 10
+    TUNE_POPULATION = 8
+    TUNE_SEEDS = 3
+    TUNE_COST = 0.1
+    TUNE_INITIAL_SPREAD = 1.0
+    TUNE_MUTATION_SPREAD = 0.3
 
     GOROUTINE_CAP = 10000 
-    DEPTH_CAP = 6 
+    DEPTH_CAP = cas.MaxDepth
+    DOT_DEPTH_CAP = 4
+    // Rounds a search for a rule's exact effectiveness may take before the rest is sampled:
+    EXACT_NODE_BUDGET = 2000000
+    // The most runs the HTTP API plays at once, and the biggest run it takes on (see PdServer):
+    SERVE_RUN_CAP = 4
+    SERVE_COHORT_CAP = 10000
+    SERVE_ROUND_CAP = 100000000000
 
     USE_SYSTEM_TIME = -1
     SQUELCH_NOTIFICATIONS = -1
@@ -20,9 +47,27 @@ const (
     COOPERATE = 0
     DEFECT = 1
 
+    SCORE_YEARS = 0
+    SCORE_POINTS = 1
+
+    // How the players of a game take their turns (see PdGameParams):
+    SIMULTANEOUS = 0
+    ALTERNATING = 1
+
+    // How a Classifier's state is laid out (see PdGameParams):
+    PERSPECTIVE = 0
+    JOINT = 1
+
+    // Default payoffs in years in prison:
     PUNISHMENT = 2
     REWARD = 1
     SUCKERS = 3
     TEMPTATION = 0
+
+    // Default payoffs in points (as in Axelrod's tournaments):
+    POINTS_PUNISHMENT = 1
+    POINTS_REWARD = 3
+    POINTS_SUCKERS = 0
+    POINTS_TEMPTATION = 5
 )
 
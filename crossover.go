@@ -0,0 +1,48 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/prisoners_dilemma/cas"
+)
+
+/* Makes a cas.Crossover from a spec of the form "<operator>[:<argument>]":
+       - "onepoint", single-point crossover (the default),
+       - "twopoint", two-point crossover,
+       - "kpoint:<k>", k-point crossover,
+       - "uniform[:<p>]", uniform crossover swapping each bit with chance p (0.5),
+       - "none", no crossover, so offspring are mutated clones.  */
+func MakeCrossover(spec string) (cas.Crossover, error) {
+    f := strings.SplitN(spec, ":", 2)
+    arg := ""
+    if len(f) == 2 {
+        arg = f[1]
+    }
+    switch f[0] {
+    case "", "onepoint":
+        return cas.OnePointCrossover{}, nil
+    case "twopoint":
+        return cas.KPointCrossover{K: 2}, nil
+    case "kpoint":
+        k, err := strconv.Atoi(arg)
+        if err != nil || k < 1 {
+            return nil, fmt.Errorf("k-point crossover needs a whole number of points of at least 1, got %q", arg)
+        }
+        return cas.KPointCrossover{K: k}, nil
+    case "uniform":
+        x := 0.5
+        if arg != "" {
+            var err error
+            x, err = strconv.ParseFloat(arg, 64)
+            if err != nil || x < 0 || x > 1 {
+                return nil, fmt.Errorf("uniform crossover swap probability %q must be between 0 and 1", arg)
+            }
+        }
+        return cas.UniformCrossover{P: x}, nil
+    case "none":
+        return cas.CloneCrossover{}, nil
+    }
+    return nil, fmt.Errorf("unknown crossover operator %q (expected onepoint, twopoint, kpoint, uniform or none)", f[0])
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+    "github.com/prisoners_dilemma/cas"
+)
+
+/* A game between two Classifier Agents without noise is deterministic
+   once the first mover is known: each round's moves depend only on the
+   History at the start of the round, and there are only so many
+   Histories. So the game must settle into a cycle after a transient, and
+   its scores for any number of rounds can be worked out from the
+   transient and one turn of the cycle, without playing every round.  */
+
+// Totals over some rounds of a game:
+type pdTally struct {
+    sa int
+    sb int
+    // How many times each player cooperated:
+    ca int
+    cb int
+}
+
+func (x *pdTally) add(y pdTally, k int) {
+    x.sa, x.sb, x.ca, x.cb = x.sa + k * y.sa, x.sb + k * y.sb, x.ca + k * y.ca, x.cb + k * y.cb
+}
+
+// Returns whether a game can be worked out by pdExactGame() rather than played:
+func pdIsDeterministic(a *cas.Agent, b *cas.Agent, gp PdGameParams) bool {
+    return a.Strategy() == nil && b.Strategy() == nil && gp.ActionNoise == 0 && gp.PerceptionNoise == 0
+}
+
+/* Plays one round of a deterministic game from the History h, with
+   player t (0 for a, 1 for b) moving first, just as pdGame() does.
+   Returns the History after the round, and the moves of a and b.  */
+func pdExactRound(a *cas.Agent, b *cas.Agent, gp PdGameParams, t int, h cas.History) (cas.History, int, int) {
+    p := [2]*cas.Agent{a, b}
+    var m [2]int
+    for j := 0; j < 2; j++ {
+        m[t] = p[t].CalcMoveIndex(pdStateIndex(&h, p[t].Depth(), t == 0, gp.Encoding))
+        if gp.Mode == ALTERNATING {
+            h.Record(t, m[t])
+        }
+        t = 1 - t
+    }
+    if gp.Mode == SIMULTANEOUS {
+        h.Record(0, m[0])
+        h.Record(1, m[1])
+    }
+    return h, m[0], m[1]
+}
+
+// Plays n rounds of a deterministic game from the History h:
+func pdExactRounds(a *cas.Agent, b *cas.Agent, gp PdGameParams, t int, h cas.History, n int) (cas.History, pdTally) {
+    x := pdTally{}
+    for i := 0; i < n; i++ {
+        var ra, rb int
+        h, ra, rb = pdExactRound(a, b, gp, t, h)
+        if ra == COOPERATE {
+            x.ca++
+        }
+        if rb == COOPERATE {
+            x.cb++
+        }
+        ra, rb = gp.Payoff.Scores(ra, rb)
+        x.sa += ra
+        x.sb += rb
+    }
+    return h, x
+}
+
+/* Works out the totals of a deterministic game between a and b (see
+   pdIsDeterministic()) with player t moving first, from a History of the
+   given depth. Brent's algorithm finds the length of the cycle the game
+   falls into and then the length of the transient before it, using no
+   more memory than a few Histories. The totals are those of the
+   transient, plus those of the cycle times the number of whole turns of
+   it, plus those of the last part turn. If the cycle isn't found within
+   as many rounds as the game has, the rounds are just played, which costs
+   less.  */
+func pdExactGame(a *cas.Agent, b *cas.Agent, gp PdGameParams, depth int, t int) pdTally {
+    n := gp.NumRounds
+    h0 := cas.MakeHistory(depth)
+    next := func(h cas.History) cas.History {
+        h, _, _ = pdExactRound(a, b, gp, t, h)
+        return h
+    }
+
+    // The length of the cycle, lam:
+    power, lam, steps := 1, 1, 1
+    x, y := h0, next(h0)
+    for x != y {
+        if steps > n {
+            _, s := pdExactRounds(a, b, gp, t, h0, n)
+            return s
+        }
+        if power == lam {
+            x, power, lam = y, power * 2, 0
+        }
+        y = next(y)
+        lam++
+        steps++
+    }
+
+    // The length of the transient, mu, which ends at the start of the cycle x:
+    x, y = h0, h0
+    for i := 0; i < lam; i++ {
+        y = next(y)
+    }
+    mu := 0
+    for x != y {
+        x, y = next(x), next(y)
+        mu++
+    }
+
+    if n <= mu + lam {
+        _, s := pdExactRounds(a, b, gp, t, h0, n)
+        return s
+    }
+    _, s := pdExactRounds(a, b, gp, t, h0, mu)
+    _, c := pdExactRounds(a, b, gp, t, x, lam)
+    _, e := pdExactRounds(a, b, gp, t, x, (n - mu) % lam)
+    s.add(c, (n - mu) / lam)
+    s.add(e, 1)
+    return s
+}
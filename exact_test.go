@@ -0,0 +1,68 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/util"
+)
+
+// Returns the length of the transient and the cycle of a deterministic game, the slow way:
+func pdTestCycle(a *cas.Agent, b *cas.Agent, gp PdGameParams, depth int, t int) (int, int) {
+    seen := map[cas.History]int{}
+    h := cas.MakeHistory(depth)
+    for i := 0; ; i++ {
+        if j, ok := seen[h]; ok {
+            return j, i - j
+        }
+        seen[h] = i
+        h, _, _ = pdExactRound(a, b, gp, t, h)
+    }
+}
+
+func TestPdExactGame(t *testing.T) {
+    r := util.MakeRand(24)
+    // How many games had the cycle longer than the game, the game no longer than transient and cycle, or longer:
+    var longCycle, short, long int
+    for k := 0; k < 3000; k++ {
+        a, b := cas.MakeAgent(1 + r.Intn(4), r), cas.MakeAgent(1 + r.Intn(4), r)
+        gp := PdGameParams{
+            NumRounds: 1 + r.Intn(300),
+            DecisionDepth: b.Depth(),
+            Payoff: DefaultPdPayoff(r.Intn(2)),
+            Mode: r.Intn(2),
+            Encoding: r.Intn(2),
+            Trace: true,
+        }
+        // Short games are the ones which don't reach the end of the cycle:
+        if k % 3 == 0 {
+            gp.NumRounds = 1 + r.Intn(8)
+        }
+        depth := a.Depth()
+        if b.Depth() > depth {
+            depth = b.Depth()
+        }
+        res := pdGame(&a, &b, gp, false, util.MakeRand(int64(k)))
+        tf := 1
+        if res.FirstA {
+            tf = 0
+        }
+        x := pdExactGame(&a, &b, gp, depth, tf)
+        want := pdTally{res.ScoreA, res.ScoreB, res.CooperationsA, res.CooperationsB}
+        if x != want {
+            t.Fatalf("game %d (%d rounds, mode %d, encoding %d) gives %+v, but playing it out gives %+v", k, gp.NumRounds, gp.Mode, gp.Encoding, x, want)
+        }
+        mu, lam := pdTestCycle(&a, &b, gp, depth, tf)
+        switch {
+        case gp.NumRounds < lam:
+            longCycle++
+        case gp.NumRounds <= mu + lam:
+            short++
+        default:
+            long++
+        }
+    }
+    if longCycle == 0 || short == 0 || long == 0 {
+        t.Fatalf("the games didn't cover every case: %d with the cycle longer than the game, %d short, %d long", longCycle, short, long)
+    }
+}
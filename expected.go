@@ -0,0 +1,241 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "math/rand"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/util"
+)
+
+/* Works out the chance that a rule beats a random opponent, rather than
+   only sampling it. A random opponent of depth d is a rule whose 4^d
+   entries are each 0 or 1 with even odds, and it moves first half of the
+   time. A game against it only ever reads the entries of the states the
+   game passes through, so the search walks the game round by round, and
+   when the opponent needs an entry which hasn't been read yet it tries
+   both values. A whole game then has the chance 2^-(entries read), and
+   the win rate is the sum of the chances of the games the rule wins.
+
+   Once the entry of a state has been read the rest of the game from
+   that state is fixed, so if a state comes round again the game is in a
+   cycle, and it is finished in closed form (as in pdExactGame()) rather
+   than played to the end. Even so, the number of games grows with every
+   entry read, so the games which read more than limit entries are cut
+   off and only their total chance is kept (see pdRuleWinPercent()).  */
+type pdExpectedSearch struct {
+    a *cas.Agent
+    gp PdGameParams
+    // The depth of the History:
+    depth int
+    // The depth of the opponent, and the entries of its rule read so far (-1 if not):
+    d int
+    table []int8
+    // The first mover:
+    t int
+    // The round each state was reached in on the current game (-1 if not):
+    seen []int32
+    // The scores before each round of the current game, up to the first repeated state:
+    sa []int
+    sb []int
+    limit int
+    budget int
+    // The chance of a win over the games searched, and the chance of the games cut off:
+    won float64
+    cut float64
+    // If not nil, unread entries are drawn from r instead, so only one game is played:
+    r *rand.Rand
+}
+
+func makePdExpectedSearch(a *cas.Agent, gp PdGameParams) *pdExpectedSearch {
+    // The History must be deep enough for both players:
+    depth := gp.DecisionDepth
+    if a.Depth() > depth {
+        depth = a.Depth()
+    }
+    /* A game can't pass through more states than there are before one
+       comes round again and the cycle is finished in closed form, so the
+       scores are only needed for that many rounds, however long the game.  */
+    rounds := util.Pow2Int(depth * 2)
+    if gp.NumRounds < rounds {
+        rounds = gp.NumRounds
+    }
+    s := &pdExpectedSearch{
+        a: a,
+        gp: gp,
+        depth: depth,
+        d: gp.DecisionDepth,
+        table: make([]int8, util.Pow2Int(gp.DecisionDepth * 2)),
+        seen: make([]int32, util.Pow2Int(depth * 2)),
+        sa: make([]int, rounds + 1),
+        sb: make([]int, rounds + 1),
+    }
+    for i := range s.table {
+        s.table[i] = -1
+    }
+    for i := range s.seen {
+        s.seen[i] = -1
+    }
+    return s
+}
+
+/* Searches every game which reads no more than limit entries, from
+   either seat. Returns false if it takes more than budget rounds in all,
+   and otherwise leaves the results in won and cut.  */
+func (s *pdExpectedSearch) search(limit int, budget int) bool {
+    s.limit, s.budget, s.r = limit, budget, nil
+    s.won, s.cut = 0, 0
+    for t := 0; t < 2; t++ {
+        s.t = t
+        if !s.round(cas.MakeHistory(s.depth), 0, 0) {
+            return false
+        }
+    }
+    // Each first mover is half the games:
+    s.won /= 2
+    s.cut /= 2
+    return true
+}
+
+/* Plays one game against a random opponent drawn from r. Returns whether
+   a won it having read more than limit entries, i.e. whether it was one
+   of the games won which search(limit, ...) cut off.  */
+func (s *pdExpectedSearch) sample(limit int, r *rand.Rand) bool {
+    s.limit, s.budget, s.r = limit, math.MaxInt32, r
+    s.won, s.t = 0, r.Intn(2)
+    s.round(cas.MakeHistory(s.depth), 0, 0)
+    return s.won > 0
+}
+
+// Adds a finished game which read entries of the opponent:
+func (s *pdExpectedSearch) finish(sa int, sb int, read int) {
+    if !s.gp.Payoff.Better(sa, sb) {
+        return
+    }
+    if s.r == nil {
+        s.won += math.Ldexp(1, -read)
+    } else if read > s.limit {
+        s.won++
+    }
+}
+
+/* Searches every game which goes on from the History h at the start of
+   round i, where read entries of the opponent have been read so far.
+   Returns false if the budget runs out.  */
+func (s *pdExpectedSearch) round(h cas.History, i int, read int) bool {
+    n, gp := s.gp.NumRounds, s.gp
+    if i == n {
+        s.finish(s.sa[n], s.sb[n], read)
+        return true
+    }
+
+    // A state seen before on this game starts a cycle, which is finished in closed form:
+    k := h.Index(h.Depth(), 0)
+    if j := int(s.seen[k]); j >= 0 {
+        q, rem := (n - i) / (i - j), (n - i) % (i - j)
+        sa := s.sa[i] + q * (s.sa[i] - s.sa[j]) + s.sa[j + rem] - s.sa[j]
+        sb := s.sb[i] + q * (s.sb[i] - s.sb[j]) + s.sb[j + rem] - s.sb[j]
+        s.finish(sa, sb, read)
+        return true
+    }
+    s.budget--
+    if s.budget < 0 {
+        return false
+    }
+
+    /* a moves from the state at the start of the round, unless it moves
+       second in the alternating game, and the opponent's entry for the
+       round is only known once the first mover has moved.  */
+    second := gp.Mode == ALTERNATING && s.t == 1
+    ma := 0
+    hb := h
+    if !second {
+        ma = s.a.CalcMoveIndex(pdStateIndex(&h, s.a.Depth(), true, gp.Encoding))
+        if gp.Mode == ALTERNATING {
+            hb.Record(0, ma)
+        }
+    }
+    e := pdStateIndex(&hb, s.d, false, gp.Encoding)
+    moves := []int{int(s.table[e])}
+    fresh := moves[0] < 0
+    if fresh {
+        switch {
+        case s.r != nil:
+            moves[0] = s.r.Intn(2)
+        case read == s.limit:
+            s.cut += math.Ldexp(1, -read)
+            return true
+        default:
+            moves = []int{COOPERATE, DEFECT}
+        }
+        read++
+    }
+
+    s.seen[k] = int32(i)
+    ok := true
+    for _, mb := range moves {
+        s.table[e] = int8(mb)
+        x, m := h, ma
+        if second {
+            x.Record(1, mb)
+            m = s.a.CalcMoveIndex(pdStateIndex(&x, s.a.Depth(), true, gp.Encoding))
+            x.Record(0, m)
+        } else {
+            x.Record(0, m)
+            x.Record(1, mb)
+        }
+        ra, rb := gp.Payoff.Scores(m, mb)
+        s.sa[i + 1], s.sb[i + 1] = s.sa[i] + ra, s.sb[i] + rb
+        if ok = s.round(x, i + 1, read); !ok {
+            break
+        }
+    }
+    if fresh {
+        s.table[e] = -1
+    }
+    s.seen[k] = -1
+    return ok
+}
+
+/* Returns a's win percentage against random opponents, and the
+   percentage of the games which had to be sampled rather than searched,
+   which is 0 if the win percentage is exact. The search is run with
+   higher and higher limits until it either cuts off no games or takes
+   more than EXACT_NODE_BUDGET rounds, and the games cut off by the last
+   one to finish are then sampled: samples random opponents are played,
+   and those won by a game the search had cut off are counted. So the
+   sampling error is that of plain sampling, scaled down by the chance of
+   the games cut off. Rules with native strategies, and noisy games, are
+   only sampled.  */
+func pdRuleWinPercent(a *cas.Agent, gp PdGameParams, samples int, squelch bool, r *rand.Rand) (float64, float64) {
+    if a.Strategy() != nil || gp.ActionNoise > 0 || gp.PerceptionNoise > 0 {
+        return pdTestAgentAgainstSamples(a, gp, samples, squelch, r), 100
+    }
+    s := makePdExpectedSearch(a, gp)
+    won, cut, limit := 0.0, 1.0, -1
+    for l := 0; cut > 0; l++ {
+        if !s.search(l, EXACT_NODE_BUDGET) {
+            break
+        }
+        won, cut, limit = s.won, s.cut, l
+    }
+    if cut > 0 {
+        w := 0
+        for i := 0; i < samples; i++ {
+            if s.sample(limit, r) {
+                w++
+            }
+        }
+        won += float64(w) / float64(samples)
+    }
+    return 100 * won, 100 * cut
+}
+
+// Says how much of a win percentage from pdRuleWinPercent() was sampled:
+func pdSampledString(x float64) string {
+    if x == 0 {
+        return "exact"
+    }
+    return fmt.Sprintf("%.02f percent of games sampled", x)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+    "math"
+    "testing"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/util"
+)
+
+// Works out a's win percentage against every depth 1 opponent, of which there are only 16:
+func pdTestWinPercentDepth1(t *testing.T, a *cas.Agent, gp PdGameParams) float64 {
+    depth := a.Depth()
+    won := 0
+    for x := 0; x < 16; x++ {
+        c, err := cas.MakeClassifierFromRule([]int{x & 1, x >> 1 & 1, x >> 2 & 1, x >> 3 & 1})
+        if err != nil {
+            t.Fatal(err)
+        }
+        b := cas.MakeAgentFromClassifier(c)
+        for first := 0; first < 2; first++ {
+            y := pdExactGame(a, &b, gp, depth, first)
+            if gp.Payoff.Better(y.sa, y.sb) {
+                won++
+            }
+        }
+    }
+    return 100 * float64(won) / 32
+}
+
+// A depth 1 opponent has only 16 rules, so every game against one can be played:
+func TestPdRuleWinPercentExact(t *testing.T) {
+    r := util.MakeRand(25)
+    for k := 0; k < 200; k++ {
+        a := cas.MakeAgent(1 + r.Intn(3), r)
+        gp := PdGameParams{
+            NumRounds: 1 + r.Intn(150),
+            DecisionDepth: 1,
+            Payoff: DefaultPdPayoff(r.Intn(2)),
+            Mode: r.Intn(2),
+            Encoding: r.Intn(2),
+        }
+        want := pdTestWinPercentDepth1(t, &a, gp)
+        x, sampled := pdRuleWinPercent(&a, gp, 1, true, r)
+        if sampled != 0 || math.Abs(x - want) > 1e-9 {
+            t.Fatalf("rule %s (%d rounds, mode %d, encoding %d) gives %g with %g percent sampled, want exactly %g", a.Encode(), gp.NumRounds, gp.Mode, gp.Encoding, x, sampled, want)
+        }
+    }
+}
+
+// The search only keeps the scores up to the first repeated state, so a game as long as it can be costs no more:
+func TestPdRuleWinPercentLongGame(t *testing.T) {
+    r := util.MakeRand(2025)
+    for k := 0; k < 20; k++ {
+        a := cas.MakeAgent(1 + r.Intn(8), r)
+        gp := PdGameParams{NumRounds: math.MaxInt32, DecisionDepth: 1, Payoff: DefaultPdPayoff(k % 2), Mode: k / 2 % 2, Encoding: k / 4 % 2}
+        s := makePdExpectedSearch(&a, gp)
+        if n := util.Pow2Int(a.Depth() * 2) + 1; len(s.sa) != n || len(s.sb) != n {
+            t.Fatalf("a game of %d rounds at depth %d keeps %d scores, want %d", gp.NumRounds, a.Depth(), len(s.sa), n)
+        }
+        want := pdTestWinPercentDepth1(t, &a, gp)
+        x, sampled := pdRuleWinPercent(&a, gp, 1, true, r)
+        if sampled != 0 || math.Abs(x - want) > 1e-9 {
+            t.Fatalf("rule %s (mode %d, encoding %d) gives %g with %g percent sampled, want exactly %g", a.Encode(), gp.Mode, gp.Encoding, x, sampled, want)
+        }
+    }
+}
+
+/* With a limit low enough to cut off games, the games searched plus the
+   games cut off, sampled, still come to the win rate.  */
+func TestPdExpectedSearchCut(t *testing.T) {
+    r := util.MakeRand(52)
+    const samples = 200000
+    for k := 0; k < 4; k++ {
+        a := cas.MakeAgent(2, r)
+        gp := PdGameParams{NumRounds: 100, DecisionDepth: 2, Payoff: DefaultPdPayoff(0), Mode: k % 2, Encoding: k / 2}
+        s := makePdExpectedSearch(&a, gp)
+        if !s.search(3, math.MaxInt32) {
+            t.Fatalf("the search ran out of budget")
+        }
+        if s.cut == 0 {
+            t.Fatalf("rule %s had no games cut off", a.Encode())
+        }
+        won := s.won
+        w := 0
+        for i := 0; i < samples; i++ {
+            if s.sample(3, r) {
+                w++
+            }
+        }
+        x := 100 * (won + float64(w) / samples)
+
+        // Against the whole search, the only error is in sampling the games cut off:
+        if !s.search(math.MaxInt32, math.MaxInt32) || s.cut != 0 {
+            t.Fatalf("the whole search of rule %s didn't finish", a.Encode())
+        }
+        if exact := 100 * s.won; math.Abs(x - exact) > 0.5 {
+            t.Errorf("rule %s gives %.3f with games cut off, but %.3f exactly", a.Encode(), x, exact)
+        }
+        if y := pdTestAgentAgainstSamples(&a, gp, samples, true, r); math.Abs(x - y) > 0.75 {
+            t.Errorf("rule %s gives %.3f with games cut off, but %.3f by plain sampling", a.Encode(), x, y)
+        }
+    }
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/lock"
+    "github.com/prisoners_dilemma/util"
+)
+
+// How the islands of RunIslands() trade members:
+type PdIslandParams struct {
+    // The number of Agents each island sends out (0 for no migration):
+    Migrants int
+    // Migration happens after every Interval generations:
+    Interval int
+    // "ring", "full" or "random" (see cas.Topology):
+    Topology string
+}
+
+func (ip *PdIslandParams) topology() (cas.Topology, error) {
+    switch ip.Topology {
+    case "", "ring":
+        return cas.RING, nil
+    case "full":
+        return cas.FULLY_CONNECTED, nil
+    case "random":
+        return cas.RANDOM_NEIGHBOR, nil
+    }
+    return cas.RING, fmt.Errorf("unknown migration topology %q (expected ring, full or random)", ip.Topology)
+}
+
+/* Makes the parameters of each island from those of the run and a spec.
+   The spec is either a number of islands which all use the parameters
+   of the run, or a list of islands separated by "/", each a list of
+   "<key>=<value>" settings separated by "+" which override the run's,
+   e.g. "selection=rank+mutation=self/crossover=uniform/" for three
+   islands. The keys are cohortSize, rThreshold, gamesPerGen,
+   mutationFrequency, mutation, selection, crossover and opponents. The
+   game itself is the same on every island, so that their fitness can be
+   compared.  */
+func MakeIslands(base DiscoverPdRuleParams, spec string) ([]DiscoverPdRuleParams, error) {
+    if k, err := strconv.Atoi(spec); err == nil {
+        if k < 1 {
+            return nil, fmt.Errorf("number of islands %d must be at least 1", k)
+        }
+        s := make([]DiscoverPdRuleParams, k)
+        for i := range s {
+            s[i] = base
+        }
+        return s, nil
+    }
+    var s []DiscoverPdRuleParams
+    for i, island := range strings.Split(spec, "/") {
+        p := base
+        for _, item := range strings.Split(island, "+") {
+            if item == "" {
+                continue
+            }
+            kv := strings.SplitN(item, "=", 2)
+            if len(kv) != 2 {
+                return nil, fmt.Errorf("island %d setting %q must be of the form <key>=<value>", i + 1, item)
+            }
+            k, v := kv[0], kv[1]
+            var n *int
+            switch k {
+            case "cohortSize":
+                n = &p.CohortSize
+            case "rThreshold":
+                n = &p.ResourceThreshold
+            case "gamesPerGen":
+                n = &p.GamesPerGen
+            case "mutationFrequency":
+                n = &p.MutationFrequency
+            case "mutation":
+                p.Mutation = v
+            case "selection":
+                p.Selection = v
+            case "crossover":
+                p.Crossover = v
+            case "opponents":
+                p.Opponents = v
+            default:
+                return nil, fmt.Errorf("island %d setting %q can't be set per island", i + 1, k)
+            }
+            if n != nil {
+                x, err := strconv.Atoi(v)
+                if err != nil || x < 1 {
+                    return nil, fmt.Errorf("island %d setting %s=%q must be a whole number of at least 1", i + 1, k, v)
+                }
+                *n = x
+            }
+        }
+        if err := p.Validate(); err != nil {
+            return nil, fmt.Errorf("island %d: %v", i + 1, err)
+        }
+        s = append(s, p)
+    }
+    return s, nil
+}
+
+// How one island of RunIslands() ended up:
+type PdIslandReport struct {
+    Island int
+    CohortSize int
+    Mutation string
+    Selection string
+    Crossover string
+    Opponents string
+    Fitness float64
+    // The island's own champion, in the portable string format:
+    EncodedRule string
+}
+
+type PdIslandResult struct {
+    Islands []PdIslandReport
+    Migrants int
+    Interval int
+    Topology string
+    Migrations int
+    GenerationsUsed int
+    // The fitness of all of the islands together, weighted by games played:
+    GlobalFitness float64
+    // The island whose champion beat the other islands' champions:
+    ChampionIsland int
+    Rule []int
+    EncodedRule string
+    RuleWinPercent float64
+    RuleSampledPercent float64
+    Classics []PdBenchmark
+    Seed int64
+}
+
+/* Evolves a Cohort on each island side by side, as DiscoverPdRule()
+   does, but every Interval generations the best Migrants members of each
+   island move to another island along the topology, after the games and
+   before the islands evolve (see cas.Migrate()). Isolated islands can
+   each climb a different hill, and migration lets the good rules of one
+   island mix with another's. The run ends when the global fitness
+   reaches the fitness goal of the first island or it hits that island's
+   generation cap. Each island then picks its champion, the champions
+   play each other for the title, and the global champion is tested
+   against random opponents and the classic strategies. The islands play
+   and evolve each generation in parallel, but every island has its own
+   PRNG streams, derived from the seed of the first island, so a run
+   comes out the same however they are scheduled.  */
+func RunIslands(ps []DiscoverPdRuleParams, ip PdIslandParams) (PdIslandResult, error) {
+    if len(ps) == 0 {
+        return PdIslandResult{}, fmt.Errorf("there are no islands")
+    }
+    t, err := ip.topology()
+    if err != nil {
+        return PdIslandResult{}, err
+    }
+    if ip.Migrants < 0 || (ip.Migrants > 0 && ip.Interval < 1) {
+        return PdIslandResult{}, fmt.Errorf("migration of %d Agents every %d generations is impossible", ip.Migrants, ip.Interval)
+    }
+    k := len(ps)
+    p := ps[0]
+    gp := p.Game()
+    c := make([]*cas.Cohort, k)
+    sel := make([]cas.Selector, k)
+    x := make([]cas.Crossover, k)
+    m := make([]cas.Mutator, k)
+    o := make([]OpponentPool, k)
+    for i := range ps {
+        if err := ps[i].Validate(); err != nil {
+            return PdIslandResult{}, fmt.Errorf("island %d: %v", i + 1, err)
+        }
+        sel[i], _ = MakeSelector(ps[i].Selection, ps[i].ResourceThreshold)
+        x[i], _ = MakeCrossover(ps[i].Crossover)
+        m[i], _ = MakeMutator(ps[i].Mutation, ps[i].MutationFrequency, p.GenerationCap)
+        o[i], _ = MakeOpponentPool(ps[i].Opponents)
+        h := cas.MakeCohort(ps[i].CohortSize, p.DecisionDepth, util.MakeRand(util.DeriveSeed(p.Seed, -1, i)))
+        c[i] = &h
+    }
+
+    if !p.Squelch {
+        fmt.Printf("Evolving %d islands...\n", k)
+    }
+    res := PdIslandResult{Migrants: ip.Migrants, Interval: ip.Interval, Topology: ip.Topology, Seed: p.Seed}
+    start := time.Now()
+    for g := 0; ; {
+        if !p.Squelch {
+            fmt.Printf("Generation %d / %d\n", g, p.GenerationCap - 1)
+        }
+
+        // Play the generation on every island at once:
+        st := make([]GenerationStats, k)
+        pl := lock.MakePool(k)
+        for i := range c {
+            j := i
+            pl.Go(func() {
+                r := util.MakeRand(util.DeriveSeed(p.Seed, g, j))
+                coop := pdGeneration(c[j], gp, ps[j].GamesPerGen, o[j], r)
+                st[j] = pdGenerationStats(c[j], coop)
+                st[j].Island = j + 1
+                m[j].Observe(g, c[j].Fitness())
+            })
+        }
+        pl.Join()
+        won, games := 0.0, 0.0
+        for i := range c {
+            n := float64(c[i].Size() * ps[i].GamesPerGen)
+            won += c[i].Fitness() * n
+            games += n
+        }
+        res.GlobalFitness = won / games
+
+        // Trade members between the islands:
+        if ip.Migrants > 0 && (g + 1) % ip.Interval == 0 {
+            cas.Migrate(c, ip.Migrants, t, util.MakeRand(util.DeriveSeed(p.Seed, g, -1)))
+            res.Migrations++
+        }
+
+        // Evolve every island at once, and then report on them in order:
+        for i := range c {
+            j := i
+            pl.Go(func() {
+                r := util.MakeRand(util.DeriveSeed(p.Seed, g, j, -1))
+                st[j].Reproducers = c[j].Evolve(sel[j], x[j], m[j], g + 1, r)
+                st[j].MutationRate = pdMutationRate(c[j])
+            })
+        }
+        pl.Join()
+        for i := range c {
+            if p.Stats != nil {
+                st[i].Seconds = time.Since(start).Seconds()
+                if err := p.Stats.Write(st[i]); err != nil {
+                    return PdIslandResult{}, err
+                }
+            }
+            if !p.Squelch {
+                fmt.Printf("\tIsland %d Fitness: %.02f\n", i + 1, st[i].Fitness)
+            }
+        }
+        g++
+        if !p.Squelch {
+            fmt.Printf("\tGlobal Fitness: %.02f\n", res.GlobalFitness)
+        }
+        if g >= p.GenerationCap || res.GlobalFitness >= float64(p.FitnessGoal) {
+            res.GenerationsUsed = g
+            break
+        }
+    }
+
+    // Crown each island's champion, and then the best of them:
+    if !p.Squelch {
+        fmt.Println("Finding champions...")
+    }
+    r := util.MakeRand(util.DeriveSeed(p.Seed, res.GenerationsUsed))
+    e := make([]*cas.Agent, k)
+    for i := range c {
+        e[i] = pdChamp(c[i], gp, r)
+        res.Islands = append(res.Islands, PdIslandReport{
+            Island: i + 1,
+            CohortSize: c[i].Size(),
+            Mutation: m[i].Name(),
+            Selection: sel[i].Name(),
+            Crossover: x[i].Name(),
+            Opponents: o[i].Name(),
+            Fitness: c[i].Fitness(),
+            EncodedRule: e[i].Encode(),
+        })
+    }
+    v := pdBestOf(e, gp, r)
+    for i := range e {
+        if e[i] == v {
+            res.ChampionIsland = i + 1
+        }
+    }
+    res.Rule, res.EncodedRule = v.Rule(), v.Encode()
+    if !p.Squelch {
+        fmt.Printf("Testing Champion against random opponents...\n")
+    }
+    res.RuleWinPercent, res.RuleSampledPercent = pdRuleWinPercent(v, gp, p.ControlSampleSize, true, r)
+    if p.ClassicGames > 0 {
+        res.Classics = pdTestAgentAgainstClassics(v, gp, p.ClassicGames, r)
+    }
+    return res, nil
+}
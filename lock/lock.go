@@ -1,8 +1,18 @@
 package lock
 
+import "sync"
+
+/* A Lock tracks a fixed number of members which are each either "busy" or
+   finished, and lets a goroutine block until all of them are finished.
+   The state is guarded by a mutex, and waiting is done on a condition
+   variable, so joining doesn't burn a CPU core. It is kept behind
+   pointers so that a Lock can be passed around by value.  */
 type Lock struct {
     size int
     busy []bool
+    left int
+    mu *sync.Mutex
+    done *sync.Cond
 }
 
 func (lk *Lock) Size() int {
@@ -12,6 +22,9 @@ func (lk *Lock) Size() int {
 func (lk *Lock) init(n int) {
     lk.size = n
     lk.busy = make([]bool, n)
+    lk.left = 0
+    lk.mu = &sync.Mutex{}
+    lk.done = sync.NewCond(lk.mu)
 }
 
 func MakeLock(n int) Lock {
@@ -22,33 +35,39 @@ func MakeLock(n int) Lock {
 
 // Sets all members to "busy":
 func (lk *Lock) ToggleAllBusy() {
+    lk.mu.Lock()
+    defer lk.mu.Unlock()
     for i:= range lk.busy {
         lk.busy[i] = true
     }
+    lk.left = lk.size
 }
 
 // Releases the concurrency lock for a given member.  
 func (lk *Lock) ToggleFinished(n int) {
-    lk.busy[n] = false
+    lk.mu.Lock()
+    defer lk.mu.Unlock()
+    if lk.busy[n] {
+        lk.busy[n] = false
+        lk.left--
+        if lk.left == 0 {
+            lk.done.Broadcast()
+        }
+    }
 }
 
 // Returns true if all members are unlocked.  
 func (lk *Lock) AllFinished() bool {
-    for i := range lk.busy {
-        if lk.busy[i] {
-            return false
-        }
-    }
-    return true
+    lk.mu.Lock()
+    defer lk.mu.Unlock()
+    return lk.left == 0
 }
 
-/* Delays execution until all members of the Lock are
-   no longer busy.  */
+/* Blocks until all members of the Lock are no longer busy.  */
 func (lk *Lock) ConcurrentJoin() {
-    for ;; {
-        if lk.AllFinished() {
-            break
-        }
+    lk.mu.Lock()
+    defer lk.mu.Unlock()
+    for lk.left > 0 {
+        lk.done.Wait()
     }
 }
-
@@ -0,0 +1,50 @@
+package lock
+
+import "sync"
+
+/* A Pool runs jobs on goroutines, with at most a fixed number of them
+   running at once. Go() blocks while the Pool is full, rather than
+   spinning, and Join() blocks until every job handed to the Pool has
+   returned. Like Lock, it can be passed around by value.  */
+type Pool struct {
+    size int
+    slots chan struct{}
+    wg *sync.WaitGroup
+}
+
+func (p *Pool) Size() int {
+    return p.size
+}
+
+func (p *Pool) init(n int) {
+    if n < 1 {
+        n = 1
+    }
+    p.size = n
+    p.slots = make(chan struct{}, n)
+    p.wg = &sync.WaitGroup{}
+}
+
+func MakePool(n int) Pool {
+    p := Pool{}
+    p.init(n)
+    return p
+}
+
+// Runs f on a new goroutine as soon as the Pool has room for it:
+func (p *Pool) Go(f func()) {
+    p.slots <- struct{}{}
+    p.wg.Add(1)
+    go func() {
+        defer func() {
+            <-p.slots
+            p.wg.Done()
+        }()
+        f()
+    }()
+}
+
+// Blocks until every job handed to the Pool so far has finished:
+func (p *Pool) Join() {
+    p.wg.Wait()
+}
@@ -0,0 +1,32 @@
+package lock
+
+import (
+    "sync/atomic"
+    "testing"
+)
+
+func TestPool(t *testing.T) {
+    size, jobs := 8, 9999
+    p := MakePool(size)
+    var cur, peak, done int64
+    for i := 0; i < jobs; i++ {
+        p.Go(func() {
+            n := atomic.AddInt64(&cur, 1)
+            for {
+                m := atomic.LoadInt64(&peak)
+                if n <= m || atomic.CompareAndSwapInt64(&peak, m, n) {
+                    break
+                }
+            }
+            atomic.AddInt64(&done, 1)
+            atomic.AddInt64(&cur, -1)
+        })
+    }
+    p.Join()
+    if done != int64(jobs) {
+        t.Fatalf("%d / %d jobs finished before Join() returned", done, jobs)
+    }
+    if peak > int64(size) {
+        t.Fatalf("%d jobs ran at once in a Pool of %d", peak, size)
+    }
+}
@@ -2,67 +2,126 @@ package main
 
 import (
     "fmt"
+    "math"
     "os"
+    "strconv"
     "strings"
-    "time"
 
+    "github.com/prisoners_dilemma/cas"
     "github.com/prisoners_dilemma/util"
 )
 
+// A subcommand of the program:
+type pdCommand struct {
+    name string
+    summary string
+    run func(args []string) error
+}
+
+var pdCommands = []pdCommand{
+    {"discover", "evolve a rule for Prisoner's Dilemma (the default)", runDiscover},
+    {"evaluate", "test a stored rule against random Agents and the classic strategies", runEvaluate},
+    {"tournament", "run a round-robin tournament between rules and classic strategies", runTournament},
+    {"play", "play one game between two rules or strategies, move by move", runPlay},
+    {"spatial", "evolve rules on a lattice, where cells play and copy their neighbors", runSpatial},
+    {"islands", "evolve several Cohorts which trade members", runIslands},
+    {"tune", "tune the parameters of discover with a meta-GA", runTune},
+    {"serve", "serve a JSON-over-HTTP API which starts and watches runs", runServe},
+    {"render", "draw a stored rule as a PNG heatmap and/or a Graphviz state diagram", runRender},
+}
+
+func pdUsage() {
+    fmt.Fprintf(os.Stderr, "Usage: prisoners_dilemma <command> [flags] [arguments]\n\nCommands:\n")
+    for _, c := range pdCommands {
+        fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.summary)
+    }
+    fmt.Fprintf(os.Stderr, "\nWith no command, or only flags, the command is discover.\n")
+    fmt.Fprintf(os.Stderr, "Run 'prisoners_dilemma <command> -help' for the flags of a command.\n")
+}
+
+/* format: ./prisoners_dilemma <command> -seed=42 -notifications (etc...)
+   Exits with status 2 on a bad command line, and 1 if the run fails.  */
 func main() {
-    // format: ./prisoners_dilemma -seed=42 -notifications=1 (etc...)
-    args := map[string]int {
-        "-decisionDepth=": DECISION_DEPTH,
-        "-cohortSize=": COHORT_SIZE,
-        "-numRounds=": NUM_ROUNDS,
-        "-rThreshold=": REPRODUCTION_THRESHOLD,
-        "-genCap=": GENERATION_CAP,
-        "-fitGoal=": FITNESS_GOAL,
-        "-seed=": USE_SYSTEM_TIME,
-        "-notifications=": SQUELCH_NOTIFICATIONS,
-        "-mutationFrequency=": MUTATION_FREQUENCY,
-        "-controlSampleSize=": RANDOM_SAMPLE_SIZE,
-        "-gamesPerGen=": GAMES_PER_GENERATION,
-    }
-    // Collect and parse the args from the command line (if any):
-    for i := range os.Args { 
-        s := strings.SplitAfter(os.Args[i], "=")
-        _, ok := args[s[0]]
-        if ok {
-            var v int
-            fmt.Sscanf(s[1], "%d", &v)
-            args[s[0]] = v
+    os.Exit(pdExitStatus(os.Stderr, runPdCommand(os.Args[1:])))
+}
+
+func runPdCommand(args []string) error {
+    if len(args) == 0 || strings.HasPrefix(args[0], "-") && !pdIsHelp(args[0]) {
+        return runDiscover(args)
+    }
+    if pdIsHelp(args[0]) || args[0] == "help" {
+        if len(args) > 1 {
+            return runPdCommand([]string{args[1], "-help"})
         }
+        pdUsage()
+        return nil
     }
-
-    var seed int64
-    if args["-seed="] == USE_SYSTEM_TIME {
-        seed = time.Now().UnixNano()
-    } else {
-        seed = int64(args["-seed="])
+    for _, c := range pdCommands {
+        if c.name == args[0] {
+            return c.run(args[1:])
+        }
     }
+    return pdUsagef("", "unknown command %q", args[0])
+}
 
-    squelch := args["-notifications="] == SQUELCH_NOTIFICATIONS
+func pdIsHelp(s string) bool {
+    return s == "-h" || s == "-help" || s == "--help"
+}
+
+func runDiscover(args []string) error {
+    f := makePdFlags("discover", "", "Evolves a Cohort of rules for Prisoner's Dilemma until its fitness reaches the\ngoal or it hits the generation cap, then tests the champion.")
+    f.game()
+    f.evolution()
+    f.testing()
+    f.stats()
+    f.fs.StringVar(&f.checkpointFile, "checkpointFile", "", "write a snapshot of the run to this file")
+    f.intVar(&f.checkpointEvery, "checkpointEvery", 0, math.MaxInt32, "generations between snapshots (0 for none)")
+    f.fs.StringVar(&f.resume, "resume", "", "continue the run in this snapshot")
+    f.fs.StringVar(&f.exportRule, "exportRule", "", "write the champion's rule to this file")
+    f.fs.StringVar(&f.png, "png", "", "draw the champion's rule as a heatmap in this PNG file")
+    f.fs.StringVar(&f.dot, "dot", "", "draw the champion's rule as a state diagram in this Graphviz DOT file")
+    if _, err := f.parse(args, 0); err != nil {
+        return err
+    }
+    p := f.params()
 
-    depth := args["-decisionDepth="]
-    if depth > DEPTH_CAP {
-        depth = DEPTH_CAP
+    /* A resumed run takes its parameters from the checkpoint, apart from
+       the ones which only affect the output, and the generation cap and
+       fitness goal, which can be raised to let a run go on for longer.  */
+    if f.resume != "" {
+        ck, err := LoadPdCheckpoint(f.resume)
+        if err != nil {
+            return err
+        }
+        q := ck.Params
+        q.Squelch = p.Squelch
+        q.CheckpointFile, q.CheckpointEvery = p.CheckpointFile, p.CheckpointEvery
+        if f.given("genCap") {
+            q.GenerationCap = p.GenerationCap
+        }
+        if f.given("fitGoal") {
+            q.FitnessGoal = p.FitnessGoal
+        }
+        q.Resume, q.ResumeMutator, q.ResumeOpponents = ck.Cohort, ck.Mutator, ck.Opponents
+        q.ResumeRounds, q.ResumeSeconds = ck.RoundsPlayed, ck.Seconds
+        p = q
+    }
+    if err := p.Validate(); err != nil {
+        return &pdUsageError{"discover", err}
     }
+    closeStats, err := f.openStats(&p)
+    if err != nil {
+        return err
+    }
+    defer closeStats()
 
     fmt.Println("... computing ...")
 
     // Discover a rule for Prisoner's Dilemma:
-    r := DiscoverPdRule(seed,
-                        args["-cohortSize="],
-                        squelch,
-                        args["-numRounds="],
-                        depth,
-                        args["-rThreshold="],
-                        args["-genCap="],
-                        args["-fitGoal="],
-                        args["-mutationFrequency="],
-                        args["-controlSampleSize="],
-                        args["-gamesPerGen="])
+    r, err := DiscoverPdRule(p)
+    if err != nil {
+        return err
+    }
 
     // Results:
     fmt.Println("Rule discovered! Results:")
@@ -71,7 +130,8 @@ func main() {
         fmt.Print(r.Rule[i])
     }
     fmt.Printf("\n")
-    fmt.Printf("\tRule effectiveness: %.02f percent\n", r.RuleWinPercent)
+    fmt.Printf("\tEncoded rule: %s\n", r.EncodedRule)
+    fmt.Printf("\tRule effectiveness: %.02f percent (%s)\n", r.RuleWinPercent, pdSampledString(r.RuleSampledPercent))
     fmt.Printf("\tIt took %d / %d generations.\n", r.GenerationsUsed, r.GenerationCap)
     fmt.Printf("\tDecision depth used: %d rounds\n", r.DecisionDepth)
     fmt.Printf("\tCohort size used: %d Agents\n", r.CohortSize)
@@ -81,7 +141,401 @@ func main() {
     fmt.Printf("\tCohort fitness goal used: %d percent\n", r.FitnessGoal)
     fmt.Printf("\tSeed used: %x\n", r.Seed)
     fmt.Printf("\tMutation frequency used: %.02f percent\n", util.Percent(1.0, float64(r.MutationFrequency)))
+    fmt.Printf("\tMutation mode used: %s\n", r.Mutation)
+    fmt.Printf("\tSelection scheme used: %s\n", r.Selection)
+    fmt.Printf("\tCrossover operator used: %s\n", r.Crossover)
     fmt.Printf("\tControl Sample Size: %d random Agents\n", r.ControlSampleSize)
     fmt.Printf("\tGames per Agent per Generation: %d\n", r.GamesPerGen)
+    fmt.Printf("\tOpponent pool used: %s\n", r.Opponents)
+    fmt.Printf("\tPayoffs used: T=%d R=%d P=%d S=%d (%s)\n", r.Payoff.Temptation, r.Payoff.Reward, r.Payoff.Punishment, r.Payoff.Suckers, r.Payoff.ScoringName())
+    fmt.Printf("\tNoise used: %.04f action, %.04f perception\n", r.ActionNoise, r.PerceptionNoise)
+    fmt.Printf("\tGame mode used: %s\n", r.GameMode)
+    fmt.Printf("\tState encoding used: %s\n", r.Encoding)
+    printBenchmarks(r.Classics)
+
+    if f.exportRule != "" || f.png != "" || f.dot != "" {
+        a, err := cas.DecodeAgent(r.EncodedRule)
+        if err != nil {
+            return err
+        }
+        if f.exportRule != "" {
+            if err := SavePdRule(f.exportRule, &a); err != nil {
+                return err
+            }
+            fmt.Printf("\tRule exported to %s\n", f.exportRule)
+        }
+        if err := SavePdRuleImages(&a, f.png, f.dot); err != nil {
+            return err
+        }
+        printRendered(f.png, f.dot)
+    }
+    return nil
+}
+
+// Evaluates a stored rule against random opponents instead of discovering one:
+func runEvaluate(args []string) error {
+    f := makePdFlags("evaluate", "<rule>", "Tests a stored rule (a rule string, a file holding one, or a classic strategy)\nagainst random Agents and then the classic strategies.")
+    f.game()
+    f.testing()
+    f.fs.BoolVar(&f.crossCheck, "crossCheck", f.crossCheck, "also play -controlSampleSize random Agents, to check the result")
+    pos, err := f.parse(args, 1)
+    if err != nil {
+        return err
+    }
+    p := f.params()
+    if err := p.Validate(); err != nil {
+        return &pdUsageError{"evaluate", err}
+    }
+    gp := p.Game()
+    a, err := LoadPdRule(pos[0], gp.Encoding)
+    if err != nil {
+        return err
+    }
+    // A native strategy has no depth, so it is tested against random rules of the default depth:
+    if a.Depth() > 0 {
+        gp.DecisionDepth = a.Depth()
+    }
+    r := util.MakeRand(util.DeriveSeed(p.Seed, -2))
+    fmt.Printf("Testing rule against random opponents...\n")
+    x, s := pdRuleWinPercent(&a, gp, p.ControlSampleSize, true, r)
+    fmt.Printf("\tRule effectiveness: %.02f percent (%s)\n", x, pdSampledString(s))
+    if f.crossCheck {
+        fmt.Printf("Checking against %d random samples...\n", p.ControlSampleSize)
+        fmt.Printf("\tSampled effectiveness: %.02f percent\n", pdTestAgentAgainstSamples(&a, gp, p.ControlSampleSize, true, r))
+    }
+    fmt.Printf("\tRule: %s\n", a.Name())
+    fmt.Printf("\tDecision depth used: %d rounds\n", gp.DecisionDepth)
+    fmt.Printf("\tSeed used: %x\n", p.Seed)
+    if p.ClassicGames > 0 {
+        printBenchmarks(pdTestAgentAgainstClassics(&a, gp, p.ClassicGames, r))
+    }
+    return nil
+}
+
+// Runs a round-robin tournament instead of discovering a rule:
+func runTournament(args []string) error {
+    f := makePdFlags("tournament", "<entrants>", "Runs a round-robin tournament. The entrants are a comma-separated list of classic\nstrategy names, classics (for all of them), random or random:<n>, and rules (or\nfiles holding them).")
+    f.game()
+    f.depth()
+    f.intVar(&f.tournamentReps, "tournamentReps", 1, math.MaxInt32, "games each pair of entrants plays")
+    f.fs.BoolVar(&f.selfPlay, "selfPlay", f.selfPlay, "have every entrant also play itself")
+    pos, err := f.parse(args, 1)
+    if err != nil {
+        return err
+    }
+    p := f.params()
+    if err := p.Validate(); err != nil {
+        return &pdUsageError{"tournament", err}
+    }
+    r := util.MakeRand(util.DeriveSeed(p.Seed, -3))
+    e, names, err := MakeTournamentEntrants(pos[0], p.Game(), r)
+    if err != nil {
+        return &pdUsageError{"tournament", err}
+    }
+    fmt.Printf("Running a tournament between %d entrants...\n", len(e))
+    printTournament(RunTournament(e, names, p.Game(), f.tournamentReps, f.selfPlay, r))
+    fmt.Printf("\tSeed used: %x\n", p.Seed)
+    return nil
+}
+
+// Plays a single game between two entrants and shows every move:
+func runPlay(args []string) error {
+    f := makePdFlags("play", "<a> <b>", "Plays one game between two entrants, each a classic strategy name, random, or a\nrule (or a file holding one), and prints every move.")
+    f.game()
+    f.depth()
+    pos, err := f.parse(args, 2)
+    if err != nil {
+        return err
+    }
+    p := f.params()
+    if err := p.Validate(); err != nil {
+        return &pdUsageError{"play", err}
+    }
+    r := util.MakeRand(util.DeriveSeed(p.Seed, -4))
+    e, names, err := MakeTournamentEntrants(pos[0] + "," + pos[1], p.Game(), r)
+    if err != nil {
+        return &pdUsageError{"play", err}
+    }
+    gp := p.Game()
+    gp.Trace = true
+    x := pdGame(e[0], e[1], gp, false, r)
+    printGame(x, names, p)
+    return nil
+}
+
+// Plays on a lattice instead of evolving a well-mixed Cohort:
+func runSpatial(args []string) error {
+    f := makePdFlags("spatial", "<width>x<height>", "Evolves rules on a toroidal lattice of the given size, where every cell plays its\nneighbors and is replaced by the offspring of the best of them.")
+    f.game()
+    f.evolution()
+    f.testing()
+    f.stats()
+    f.choiceVar(&f.neighborhood, "neighborhood", []string{"moore", "vonneumann"}, "the neighbors of each cell")
+    f.choiceVar(&f.reproduction, "reproduction", []string{"imitate", "combine"}, "how a cell is replaced")
+    f.choiceVar(&f.update, "update", []string{"sync", "async"}, "whether every cell updates at once, or one at a time")
+    pos, err := f.parse(args, 1)
+    if err != nil {
+        return err
+    }
+    p := f.params()
+    sp := PdSpatialParams{Neighborhood: f.neighborhood, Reproduction: f.reproduction, Update: f.update}
+    if err := sp.SetGrid(pos[0]); err != nil {
+        return &pdUsageError{"spatial", err}
+    }
+    if err := sp.Validate(); err != nil {
+        return &pdUsageError{"spatial", err}
+    }
+    if err := p.Validate(); err != nil {
+        return &pdUsageError{"spatial", err}
+    }
+    closeStats, err := f.openStats(&p)
+    if err != nil {
+        return err
+    }
+    defer closeStats()
+    res, err := RunSpatialPd(p, sp)
+    if err != nil {
+        return err
+    }
+    printSpatial(res)
+    return nil
+}
+
+// Evolves several Cohorts which trade members:
+func runIslands(args []string) error {
+    f := makePdFlags("islands", "<islands>", "Evolves several Cohorts side by side. The islands are either a number of them,\nor a list of islands separated by /, each a list of <key>=<value> settings\nseparated by +.")
+    f.game()
+    f.evolution()
+    f.testing()
+    f.stats()
+    f.intVar(&f.migrants, "migrants", 0, math.MaxInt32, "Agents each island sends out each migration (0 for none)")
+    f.intVar(&f.migrationInterval, "migrationInterval", 1, math.MaxInt32, "generations between migrations")
+    f.choiceVar(&f.topology, "topology", []string{"ring", "full", "random"}, "which islands receive each island's migrants")
+    pos, err := f.parse(args, 1)
+    if err != nil {
+        return err
+    }
+    p := f.params()
+    ps, err := MakeIslands(p, pos[0])
+    if err != nil {
+        return &pdUsageError{"islands", err}
+    }
+    closeStats, err := f.openStats(&ps[0])
+    if err != nil {
+        return err
+    }
+    defer closeStats()
+    res, err := RunIslands(ps, PdIslandParams{f.migrants, f.migrationInterval, f.topology})
+    if err != nil {
+        return err
+    }
+    printIslands(res)
+    return nil
+}
+
+// Tunes the parameters of DiscoverPdRule() instead of running it once:
+func runTune(args []string) error {
+    f := makePdFlags("tune", "<meta-generations>", "Runs a genetic algorithm over the parameters of discover (cohortSize, numRounds,\nrThreshold, mutationFrequency, gamesPerGen and fitGoal) for the given number of\nmeta-generations, starting from the parameters given. The other flags are used\nfor every run, so -genCap and -controlSampleSize should be kept small.")
+    f.game()
+    f.evolution()
+    f.testing()
+    f.intVar(&f.tunePopulation, "tunePopulation", 2, math.MaxInt32, "parameter sets in each meta-generation")
+    f.intVar(&f.tuneSeeds, "tuneSeeds", 1, math.MaxInt32, "seeds each parameter set is run with")
+    f.floatVar(&f.tuneCost, "tuneCost", 0, math.MaxInt32, "score points lost per unit of cost")
+    f.choiceVar(&f.tuneCostUnit, "tuneCostUnit", []string{"rounds", "seconds"}, "unit of cost: millions of rounds played, or seconds")
+    f.fs.StringVar(&f.tuneOut, "tuneOut", "", "write every parameter set tried, with its score, to this JSON file")
+    pos, err := f.parse(args, 1)
+    if err != nil {
+        return err
+    }
+    n, err := strconv.Atoi(pos[0])
+    if err != nil || n < 1 {
+        return pdUsagef("tune", "meta-generations %q must be a whole number of at least 1", pos[0])
+    }
+    p := f.params()
+    if err := p.Validate(); err != nil {
+        return &pdUsageError{"tune", err}
+    }
+    sc, err := TunePdParams(p, PdTuneOptions{n, f.tunePopulation, f.tuneSeeds, f.tuneCost, f.tuneCostUnit})
+    if err == nil && f.tuneOut != "" {
+        err = SavePdTuneScores(f.tuneOut, sc)
+    }
+    if err != nil {
+        return err
+    }
+    printTuneScores(sc)
+    return nil
+}
+
+// Serves the HTTP API, with the parameters given as the defaults for each run:
+func runServe(args []string) error {
+    f := makePdFlags("serve", "<address>", "Serves a JSON-over-HTTP API on an address such as localhost:8080. The flags\ngiven are the defaults for each run, which a request can override.")
+    f.game()
+    f.evolution()
+    f.testing()
+    pos, err := f.parse(args, 1)
+    if err != nil {
+        return err
+    }
+    p := f.params()
+    if err := p.Validate(); err != nil {
+        return &pdUsageError{"serve", err}
+    }
+    // Defaults the server would turn down would make every run ask for less:
+    if err := pdServeLimits(&p); err != nil {
+        return &pdUsageError{"serve", err}
+    }
+    // Each run without a seed of its own gets a new one (see PdServer.start()):
+    p.Seed = f.seed
+    return ServePd(pos[0], p)
+}
+
+// Draws a stored rule instead of discovering one:
+func runRender(args []string) error {
+    f := makePdFlags("render", "<rule>", "Draws a stored rule (a rule string, a file holding one, or a classic strategy\nwhich has one: tft, tf2t, pavlov, allc or alld).")
+    f.fs.StringVar(&f.png, "png", "", "draw the rule as a heatmap in this PNG file")
+    f.fs.StringVar(&f.dot, "dot", "", "draw the rule as a state diagram in this Graphviz DOT file")
+    pos, err := f.parse(args, 1)
+    if err != nil {
+        return err
+    }
+    if f.png == "" && f.dot == "" {
+        return pdUsagef("render", "render needs -png=<file> and/or -dot=<file>")
+    }
+    a, err := LoadPdRule(pos[0], PERSPECTIVE)
+    if err != nil {
+        return err
+    }
+    if err := SavePdRuleImages(&a, f.png, f.dot); err != nil {
+        return err
+    }
+    printRendered(f.png, f.dot)
+    return nil
+}
+
+// Prints where a rule was drawn:
+func printRendered(png string, dot string) {
+    if png != "" {
+        fmt.Printf("\tRule heatmap written to %s\n", png)
+    }
+    if dot != "" {
+        fmt.Printf("\tRule state diagram written to %s\n", dot)
+    }
+}
+
+// Prints a traced game move by move, and its outcome:
+func printGame(x PdGameResult, names []string, p DiscoverPdRuleParams) {
+    how := "moving simultaneously"
+    if p.Game().Mode == ALTERNATING {
+        how = names[0] + " moving first each round"
+        if !x.FirstA {
+            how = names[1] + " moving first each round"
+        }
+    }
+    fmt.Printf("Played %s against %s for %d rounds, %s:\n", names[0], names[1], len(x.MovesA), how)
+    moves := func(m []int) string {
+        var b strings.Builder
+        for _, y := range m {
+            b.WriteString(pdMoveString(y))
+        }
+        return b.String()
+    }
+    w := len(names[0])
+    if len(names[1]) > w {
+        w = len(names[1])
+    }
+    fmt.Printf("\t%-*s %s\n", w, names[0], moves(x.MovesA))
+    fmt.Printf("\t%-*s %s\n", w, names[1], moves(x.MovesB))
+    fmt.Printf("\tScores: %d vs. %d (%s)\n", x.ScoreA, x.ScoreB, p.Payoff.ScoringName())
+    fmt.Printf("\tCooperations: %d vs. %d\n", x.CooperationsA, x.CooperationsB)
+    if x.Tie {
+        fmt.Printf("\tResult: a tie\n")
+    } else if p.Payoff.Better(x.ScoreA, x.ScoreB) {
+        fmt.Printf("\tWinner: %s\n", names[0])
+    } else {
+        fmt.Printf("\tWinner: %s\n", names[1])
+    }
+    fmt.Printf("\tSeed used: %x\n", p.Seed)
+}
+
+// Prints how a rule fared against each of the classic strategies:
+func printBenchmarks(b []PdBenchmark) {
+    if len(b) == 0 {
+        return
+    }
+    fmt.Println("\tVs. classic strategies (wins / ties / games, mean payoff per round for the rule vs. the opponent):")
+    for _, x := range b {
+        fmt.Printf("\t\t%-8s %4d / %4d / %4d   %.02f vs. %.02f\n", x.Opponent, x.Wins, x.Ties, x.Games, x.Score, x.OpponentScore)
+    }
+}
+
+// Prints the outcome of a spatial run:
+func printSpatial(res PdSpatialResult) {
+    fmt.Println("Spatial run complete! Results:")
+    fmt.Printf("\tLattice: %dx%d, %s neighborhood, %s reproduction, %s update\n", res.Width, res.Height, res.Neighborhood, res.Reproduction, res.Update)
+    fmt.Printf("\tIt ran for %d generations.\n", res.GenerationsUsed)
+    fmt.Printf("\tFinal fitness: %.02f (mutual punishment is 0, mutual reward is 100)\n", res.Fitness)
+    fmt.Printf("\tFinal cooperation rate: %.02f percent\n", res.CooperationRate)
+    fmt.Printf("\tDistinct rules at the end: %d\n", res.Genotypes)
+    fmt.Printf("\tMost common rule: ")
+    for i := range res.Rule {
+        fmt.Print(res.Rule[i])
+    }
+    fmt.Printf("\n")
+    fmt.Printf("\tEncoded rule: %s (held by %.02f percent of cells)\n", res.EncodedRule, res.RuleShare)
+    fmt.Printf("\tRule effectiveness: %.02f percent (%s)\n", res.RuleWinPercent, pdSampledString(res.RuleSampledPercent))
+    fmt.Printf("\tSeed used: %x\n", res.Seed)
+    printBenchmarks(res.Classics)
+}
+
+// Prints the outcome of an island run:
+func printIslands(res PdIslandResult) {
+    fmt.Println("Island run complete! Results:")
+    fmt.Printf("\tIt took %d generations, with %d migrations of %d Agents along a %s topology.\n", res.GenerationsUsed, res.Migrations, res.Migrants, res.Topology)
+    fmt.Printf("\tGlobal fitness: %.02f\n", res.GlobalFitness)
+    for _, x := range res.Islands {
+        fmt.Printf("\tIsland %d: fitness %.02f, %d Agents, %s mutation, %s selection, %s crossover, %s opponents\n", x.Island, x.Fitness, x.CohortSize, x.Mutation, x.Selection, x.Crossover, x.Opponents)
+        fmt.Printf("\t\tChampion: %s\n", x.EncodedRule)
+    }
+    fmt.Printf("\tGlobal champion from island %d: ", res.ChampionIsland)
+    for i := range res.Rule {
+        fmt.Print(res.Rule[i])
+    }
+    fmt.Printf("\n")
+    fmt.Printf("\tEncoded rule: %s\n", res.EncodedRule)
+    fmt.Printf("\tRule effectiveness: %.02f percent (%s)\n", res.RuleWinPercent, pdSampledString(res.RuleSampledPercent))
+    fmt.Printf("\tSeed used: %x\n", res.Seed)
+    printBenchmarks(res.Classics)
+}
+
+// Prints the best few parameter sets found by the meta-GA:
+func printTuneScores(sc []PdTuneScore) {
+    fmt.Printf("Tuning complete! %d parameter sets tried. The best:\n", len(sc))
+    fmt.Println("\tscore    win %    cost   cohortSize numRounds rThreshold mutationFrequency gamesPerGen fitGoal")
+    for i := 0; i < len(sc) && i < 5; i++ {
+        x, y := sc[i], sc[i].Params
+        fmt.Printf("\t%6.02f  %6.02f  %7.03f  %10d %9d %10d %17d %11d %7d\n", x.Score, x.WinPercent, x.Cost,
+                   y.CohortSize, y.NumRounds, y.ResourceThreshold, y.MutationFrequency, y.GamesPerGen, y.FitnessGoal)
+    }
 }
 
+// Prints the ranked standings and the pairwise score matrix of a tournament:
+func printTournament(res TournamentResult) {
+    fmt.Println("Standings (mean payoff per round, wins / ties / losses, games):")
+    for i, x := range res.Standings {
+        fmt.Printf("\t%3d. %-12s %6.03f   %5d / %5d / %5d   %6d\n", i + 1, x.Name, x.MeanScore, x.Wins, x.Ties, x.Losses, x.Games)
+    }
+    fmt.Println("Mean payoff per round of each row against each column:")
+    fmt.Printf("\t%-16s", "")
+    for i := range res.Names {
+        fmt.Printf(" %7d", i + 1)
+    }
+    fmt.Print("\n")
+    for i, n := range res.Names {
+        fmt.Printf("\t%2d. %-12s", i + 1, n)
+        for j := range res.Names {
+            fmt.Printf(" %7.03f", res.Scores[i][j])
+        }
+        fmt.Print("\n")
+    }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/prisoners_dilemma/cas"
+)
+
+/* Makes a cas.Mutator from a spec of the form "<mode>[:<argument>...]".
+   The base rate is 1/freq, as set by -mutationFrequency, and g is the
+   generation cap:
+       - "fixed", the base rate every generation (the default),
+       - "linear[:<from>[:<to>]]", a straight fall from the rate from to
+         the rate to over g generations (10 times the base rate to a tenth
+         of it),
+       - "exponential[:<from>[:<to>]]", the same, but falling by the same
+         factor every generation,
+       - "fifth[:<window>]", the 1/5th success rule, adapting the base
+         rate every window generations (10),
+       - "self[:<tau>]", a rate of each Agent's own, starting at the base
+         rate and drifting with log-normal spread tau (0.2).  */
+func MakeMutator(spec string, freq int, g int) (cas.Mutator, error) {
+    f := strings.Split(spec, ":")
+    p := 1.0
+    if freq > 1 {
+        p = 1.0 / float64(freq)
+    }
+    rate := func(i int, d float64) (float64, error) {
+        if len(f) <= i || f[i] == "" {
+            return d, nil
+        }
+        x, err := strconv.ParseFloat(f[i], 64)
+        if err != nil || x <= 0 || x > 1 {
+            return 0, fmt.Errorf("mutation rate %q must be above 0 and at most 1", f[i])
+        }
+        return x, nil
+    }
+    switch f[0] {
+    case "", "fixed":
+        if len(f) > 1 {
+            return nil, fmt.Errorf("fixed mutation takes its rate from -mutationFrequency")
+        }
+        return &cas.FixedMutator{P: p}, nil
+    case "linear", "exponential":
+        if len(f) > 3 {
+            return nil, fmt.Errorf("%s mutation takes at most two rates, got %q", f[0], spec)
+        }
+        from, err := rate(1, p * MUTATION_SCHEDULE_SPREAD)
+        if err != nil {
+            return nil, err
+        }
+        to, err := rate(2, p / MUTATION_SCHEDULE_SPREAD)
+        if err != nil {
+            return nil, err
+        }
+        if from > 1 {
+            from = 1
+        }
+        return &cas.ScheduleMutator{From: from, To: to, Span: g, Exponential: f[0] == "exponential"}, nil
+    case "fifth":
+        w := MUTATION_SUCCESS_WINDOW
+        if len(f) > 1 {
+            var err error
+            w, err = strconv.Atoi(f[1])
+            if err != nil || w < 1 || len(f) > 2 {
+                return nil, fmt.Errorf("1/5th rule window %q must be a whole number of at least 1", spec[len(f[0]) + 1:])
+            }
+        }
+        return &cas.SuccessRuleMutator{P: p, Window: w, Factor: MUTATION_SUCCESS_FACTOR}, nil
+    case "self":
+        tau := MUTATION_SELF_TAU
+        if len(f) > 1 {
+            var err error
+            tau, err = strconv.ParseFloat(f[1], 64)
+            if err != nil || tau < 0 || len(f) > 2 {
+                return nil, fmt.Errorf("self-adaptive spread %q must be a number of at least 0", spec[len(f[0]) + 1:])
+            }
+        }
+        return &cas.SelfAdaptiveMutator{P: p, Tau: tau}, nil
+    }
+    return nil, fmt.Errorf("unknown mutation mode %q (expected fixed, linear, exponential, fifth or self)", f[0])
+}
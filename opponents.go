@@ -0,0 +1,275 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "math/rand"
+    "strconv"
+    "strings"
+
+    "github.com/prisoners_dilemma/cas"
+)
+
+/* An OpponentPool supplies the opponents which the members of a Cohort
+   play during a generation of DiscoverPdRule(), and so decides what the
+   Cohort's fitness is measured against. Opponent() is called from many
+   goroutines at once, so it may only read the pool, and it hands back a
+   copy which the game can score freely without touching anything else.
+   Pools with state keep it in exported fields so that they can be
+   checkpointed as JSON.  */
+type OpponentPool interface {
+    Name() string
+    // Is shown the Cohort before each generation is played:
+    Prepare(c *cas.Cohort)
+    // Returns an opponent for member j of the Cohort, drawn from r:
+    Opponent(j int, gp PdGameParams, r *rand.Rand) cas.Agent
+    // Is shown the Cohort after each generation is played, before it evolves:
+    Observe(c *cas.Cohort)
+}
+
+// Fresh random Agents, as in John Holland's paper. The default:
+type RandomPool struct{}
+
+func (o *RandomPool) Name() string {
+    return "random"
+}
+
+func (o *RandomPool) Prepare(c *cas.Cohort) {}
+
+func (o *RandomPool) Opponent(j int, gp PdGameParams, r *rand.Rand) cas.Agent {
+    return cas.MakeAgent(gp.DecisionDepth, r)
+}
+
+func (o *RandomPool) Observe(c *cas.Cohort) {}
+
+/* The other members of the Cohort, as they stood at the start of the
+   generation, so that fitness is relative to the rest of the Cohort.  */
+type CohortPool struct {
+    members []cas.Agent
+}
+
+func (o *CohortPool) Name() string {
+    return "cohort"
+}
+
+func (o *CohortPool) Prepare(c *cas.Cohort) {
+    o.members = make([]cas.Agent, c.Size())
+    for i := range o.members {
+        o.members[i] = *c.Member(i)
+    }
+}
+
+func (o *CohortPool) Opponent(j int, gp PdGameParams, r *rand.Rand) cas.Agent {
+    k := r.Intn(len(o.members) - 1)
+    if k >= j {
+        k++
+    }
+    return o.members[k]
+}
+
+func (o *CohortPool) Observe(c *cas.Cohort) {}
+
+/* A hall of fame: the best member of each past generation (the one
+   with the most resources), keeping only the latest Size of them. Until
+   the first generation has been played it is empty, and random Agents
+   are played instead.  */
+type HallOfFamePool struct {
+    Size int `json:"size"`
+    Members []cas.Agent `json:"members"`
+}
+
+func (o *HallOfFamePool) Name() string {
+    return fmt.Sprintf("hall:%d", o.Size)
+}
+
+func (o *HallOfFamePool) Prepare(c *cas.Cohort) {}
+
+func (o *HallOfFamePool) Opponent(j int, gp PdGameParams, r *rand.Rand) cas.Agent {
+    if len(o.Members) == 0 {
+        return cas.MakeAgent(gp.DecisionDepth, r)
+    }
+    return o.Members[r.Intn(len(o.Members))]
+}
+
+func (o *HallOfFamePool) Observe(c *cas.Cohort) {
+    v := c.Member(0)
+    for i := 1; i < c.Size(); i++ {
+        if c.Member(i).Resources() > v.Resources() {
+            v = c.Member(i)
+        }
+    }
+    o.Members = append(o.Members, *v)
+    if len(o.Members) > o.Size {
+        o.Members = append([]cas.Agent{}, o.Members[len(o.Members) - o.Size:]...)
+    }
+}
+
+/* The classic strategies (see cas.ClassicStrategies()), chosen uniformly,
+   as played under each encoding (see MakeClassicAgent()):  */
+type ClassicPool struct {
+    members [2][]cas.Agent
+}
+
+func (o *ClassicPool) Name() string {
+    return "classics"
+}
+
+func (o *ClassicPool) Prepare(c *cas.Cohort) {
+    if o.members[0] != nil {
+        return
+    }
+    for _, n := range cas.ClassicStrategies() {
+        for _, enc := range []int{PERSPECTIVE, JOINT} {
+            a, _ := MakeClassicAgent(n, enc)
+            o.members[enc] = append(o.members[enc], a)
+        }
+    }
+}
+
+func (o *ClassicPool) Opponent(j int, gp PdGameParams, r *rand.Rand) cas.Agent {
+    x := o.members[gp.Encoding]
+    return x[r.Intn(len(x))]
+}
+
+func (o *ClassicPool) Observe(c *cas.Cohort) {}
+
+/* A weighted mix of other pools: each game's opponent comes from one of
+   the Pools, chosen with a chance proportional to its weight.  */
+type MixPool struct {
+    Pools []OpponentPool
+    Weights []float64
+}
+
+func (o *MixPool) Name() string {
+    s := make([]string, len(o.Pools))
+    for i := range o.Pools {
+        s[i] = fmt.Sprintf("%s=%g", o.Pools[i].Name(), o.Weights[i])
+    }
+    return "mix:" + strings.Join(s, ",")
+}
+
+func (o *MixPool) Prepare(c *cas.Cohort) {
+    for _, p := range o.Pools {
+        p.Prepare(c)
+    }
+}
+
+func (o *MixPool) Opponent(j int, gp PdGameParams, r *rand.Rand) cas.Agent {
+    t := 0.0
+    for _, w := range o.Weights {
+        t += w
+    }
+    x := r.Float64() * t
+    i := 0
+    for i < len(o.Weights) - 1 && x >= o.Weights[i] {
+        x -= o.Weights[i]
+        i++
+    }
+    return o.Pools[i].Opponent(j, gp, r)
+}
+
+func (o *MixPool) Observe(c *cas.Cohort) {
+    for _, p := range o.Pools {
+        p.Observe(c)
+    }
+}
+
+/* The state of a MixPool is the state of each of its pools, in order,
+   and it can only be read back into a MixPool made from the same spec.  */
+func (o *MixPool) MarshalJSON() ([]byte, error) {
+    return json.Marshal(o.Pools)
+}
+
+func (o *MixPool) UnmarshalJSON(b []byte) error {
+    var s []json.RawMessage
+    if err := json.Unmarshal(b, &s); err != nil {
+        return err
+    }
+    if len(s) != len(o.Pools) {
+        return fmt.Errorf("saved opponent mix has %d pools, but %d were asked for", len(s), len(o.Pools))
+    }
+    for i := range s {
+        if err := json.Unmarshal(s[i], o.Pools[i]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+/* Returns whether the members' games against a pool's opponents are
+   scored by payoff rather than won by the better score (see
+   pdGeneration()). Copies of the Cohort's own rules, and rules like
+   them, tie with each other whether they cooperate or defect throughout,
+   and since ties go to the opponent, no rule could ever do better than
+   to defect. Random opponents keep the original scoring, so that runs
+   against them are unchanged.  */
+func pdScoresByPayoff(o OpponentPool) bool {
+    switch x := o.(type) {
+    case *RandomPool:
+        return false
+    case *MixPool:
+        for _, p := range x.Pools {
+            if pdScoresByPayoff(p) {
+                return true
+            }
+        }
+        return false
+    }
+    return true
+}
+
+/* Makes an OpponentPool from a spec of the form "<pool>[:<argument>]":
+       - "random", fresh random Agents (the default),
+       - "cohort", the other members of the Cohort,
+       - "hall[:<size>]", the best members of the last size generations (50),
+       - "classics", the classic strategies,
+       - "mix:<pool>=<weight>,...", a weighted mix of the pools above, e.g.
+         "mix:cohort=2,hall:20=1,random=1".  */
+func MakeOpponentPool(spec string) (OpponentPool, error) {
+    f := strings.SplitN(spec, ":", 2)
+    arg := ""
+    if len(f) == 2 {
+        arg = f[1]
+    }
+    switch f[0] {
+    case "", "random":
+        return &RandomPool{}, nil
+    case "cohort":
+        return &CohortPool{}, nil
+    case "hall":
+        n := HALL_OF_FAME_SIZE
+        if arg != "" {
+            var err error
+            n, err = strconv.Atoi(arg)
+            if err != nil || n < 1 {
+                return nil, fmt.Errorf("hall of fame size %q must be a whole number of at least 1", arg)
+            }
+        }
+        return &HallOfFamePool{Size: n}, nil
+    case "classics":
+        return &ClassicPool{}, nil
+    case "mix":
+        m := &MixPool{}
+        for _, item := range strings.Split(arg, ",") {
+            i := strings.LastIndex(item, "=")
+            if i < 0 {
+                return nil, fmt.Errorf("opponent mix entry %q must be of the form <pool>=<weight>", item)
+            }
+            w, err := strconv.ParseFloat(item[i + 1:], 64)
+            if err != nil || w <= 0 {
+                return nil, fmt.Errorf("opponent mix weight %q must be a number above 0", item[i + 1:])
+            }
+            if strings.HasPrefix(item, "mix") {
+                return nil, fmt.Errorf("opponent mixes can't be nested")
+            }
+            p, err := MakeOpponentPool(item[:i])
+            if err != nil {
+                return nil, err
+            }
+            m.Pools = append(m.Pools, p)
+            m.Weights = append(m.Weights, w)
+        }
+        return m, nil
+    }
+    return nil, fmt.Errorf("unknown opponent pool %q (expected random, cohort, hall, classics or mix)", f[0])
+}
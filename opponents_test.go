@@ -0,0 +1,130 @@
+package main
+
+import (
+    "encoding/json"
+    "reflect"
+    "testing"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestMakeOpponentPool(t *testing.T) {
+    tests := []struct {
+        spec string
+        // The pool's name, or "" if the spec is refused:
+        name string
+        byPayoff bool
+    }{
+        {"", "random", false},
+        {"random", "random", false},
+        {"cohort", "cohort", true},
+        {"hall", "hall:50", true},
+        {"hall:7", "hall:7", true},
+        {"classics", "classics", true},
+        {"mix:cohort=2,hall:20=1,random=1", "mix:cohort=2,hall:20=1,random=1", true},
+        {"mix:random=1", "mix:random=1", false},
+        {"hall:0", "", false},
+        {"hall:x", "", false},
+        {"mix:cohort", "", false},
+        {"mix:cohort=0", "", false},
+        {"mix:cohort=x", "", false},
+        {"mix:bogus=1", "", false},
+        {"mix:mix:random=1=1", "", false},
+        {"bogus", "", false},
+    }
+    for _, test := range tests {
+        o, err := MakeOpponentPool(test.spec)
+        if test.name == "" {
+            if err == nil {
+                t.Errorf("spec %q was accepted as %s", test.spec, o.Name())
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("spec %q was refused: %v", test.spec, err)
+            continue
+        }
+        if o.Name() != test.name {
+            t.Errorf("spec %q makes %s, want %s", test.spec, o.Name(), test.name)
+        }
+        if pdScoresByPayoff(o) != test.byPayoff {
+            t.Errorf("spec %q scores by payoff: %v, want %v", test.spec, pdScoresByPayoff(o), test.byPayoff)
+        }
+    }
+}
+
+func TestCohortPoolOpponent(t *testing.T) {
+    r := util.MakeRand(14)
+    gp := PdGameParams{DecisionDepth: 2}
+    for _, n := range []int{2, 3, 10} {
+        c := cas.MakeCohort(n, 2, r)
+        o := &CohortPool{}
+        o.Prepare(&c)
+        for j := 0; j < c.Size(); j++ {
+            seen := map[int]bool{}
+            for k := 0; k < 200; k++ {
+                b := o.Opponent(j, gp, r)
+                if b.Id() == c.Member(j).Id() {
+                    t.Fatalf("member %d of %d was drawn as its own opponent", j, c.Size())
+                }
+                seen[b.Id()] = true
+            }
+            if len(seen) != c.Size() - 1 {
+                t.Errorf("member %d of %d only met %d of the others", j, c.Size(), len(seen))
+            }
+        }
+    }
+}
+
+func TestHallOfFamePoolSize(t *testing.T) {
+    r := util.MakeRand(50)
+    o, _ := MakeOpponentPool("hall:3")
+    h := o.(*HallOfFamePool)
+    var want []int
+    for g := 0; g < 7; g++ {
+        c := cas.MakeCohort(4, 1, r)
+        c.Member(2).AddResources(1)
+        o.Observe(&c)
+        want = append(want, c.Member(2).Id())
+        if len(want) > 3 {
+            want = want[1:]
+        }
+        ids := make([]int, len(h.Members))
+        for i := range h.Members {
+            ids[i] = h.Members[i].Id()
+        }
+        if !reflect.DeepEqual(ids, want) {
+            t.Fatalf("after %d generations the hall of fame holds %v, want %v", g + 1, ids, want)
+        }
+    }
+}
+
+func TestMixPoolJSON(t *testing.T) {
+    r := util.MakeRand(20)
+    o, _ := MakeOpponentPool("mix:cohort=2,hall:2=1,random=1")
+    for g := 0; g < 3; g++ {
+        c := cas.MakeCohort(4, 2, r)
+        o.Observe(&c)
+    }
+    b, err := json.Marshal(o)
+    if err != nil {
+        t.Fatal(err)
+    }
+    p, _ := MakeOpponentPool("mix:cohort=2,hall:2=1,random=1")
+    if err := json.Unmarshal(b, p); err != nil {
+        t.Fatal(err)
+    }
+    x, y := o.(*MixPool).Pools[1].(*HallOfFamePool), p.(*MixPool).Pools[1].(*HallOfFamePool)
+    if len(y.Members) != 2 || !reflect.DeepEqual(x, y) {
+        t.Fatalf("hall of fame changed after a round trip through JSON")
+    }
+
+    // The state can only be read back into the same mix:
+    for _, spec := range []string{"mix:cohort=2,hall:2=1", "mix:cohort=2,hall:2=1,random=1,classics=1"} {
+        q, _ := MakeOpponentPool(spec)
+        if err := json.Unmarshal(b, q); err == nil {
+            t.Errorf("the state of 3 pools was read into %s", spec)
+        }
+    }
+}
@@ -0,0 +1,93 @@
+package main
+
+import "fmt"
+
+/* The payoff matrix for a game of Prisoner's Dilemma, along with the
+   convention used to score it. With SCORE_YEARS the payoffs are years in
+   prison and the player with the fewest wins. With SCORE_POINTS they are
+   points and the player with the most wins.  */
+type PdPayoff struct {
+    Temptation int
+    Reward int
+    Punishment int
+    Suckers int
+    Scoring int
+}
+
+// Returns the default payoff matrix for a scoring convention:
+func DefaultPdPayoff(scoring int) PdPayoff {
+    if scoring == SCORE_POINTS {
+        return PdPayoff{POINTS_TEMPTATION, POINTS_REWARD, POINTS_PUNISHMENT, POINTS_SUCKERS, SCORE_POINTS}
+    }
+    return PdPayoff{TEMPTATION, REWARD, PUNISHMENT, SUCKERS, SCORE_YEARS}
+}
+
+/* Returns an error if the payoffs don't make a Prisoner's Dilemma. In
+   points that means T > R > P > S and 2R > T + S, so that defecting is
+   always tempting but mutual cooperation beats taking turns exploiting
+   each other. In years every inequality is reversed.  */
+func (p PdPayoff) Validate() error {
+    t, r, u, s := p.Temptation, p.Reward, p.Punishment, p.Suckers
+    switch p.Scoring {
+    case SCORE_POINTS:
+        if !(t > r && r > u && u > s) {
+            return fmt.Errorf("payoffs (T=%d, R=%d, P=%d, S=%d) must satisfy T > R > P > S when scoring points", t, r, u, s)
+        }
+        if !(2 * r > t + s) {
+            return fmt.Errorf("payoffs (T=%d, R=%d, P=%d, S=%d) must satisfy 2R > T + S when scoring points", t, r, u, s)
+        }
+    case SCORE_YEARS:
+        if !(t < r && r < u && u < s) {
+            return fmt.Errorf("payoffs (T=%d, R=%d, P=%d, S=%d) must satisfy T < R < P < S when scoring years", t, r, u, s)
+        }
+        if !(2 * r < t + s) {
+            return fmt.Errorf("payoffs (T=%d, R=%d, P=%d, S=%d) must satisfy 2R < T + S when scoring years", t, r, u, s)
+        }
+    default:
+        return fmt.Errorf("unknown scoring convention %d", p.Scoring)
+    }
+    return nil
+}
+
+// Returns the payoffs to each player for a pair of moves:
+func (p PdPayoff) Scores(a int, b int) (int, int) {
+    if a == COOPERATE && b == COOPERATE {
+        return p.Reward, p.Reward
+    } else if a == COOPERATE && b == DEFECT {
+        return p.Suckers, p.Temptation
+    } else if a == DEFECT && b == COOPERATE {
+        return p.Temptation, p.Suckers
+    }
+    return p.Punishment, p.Punishment
+}
+
+// Returns true if score x is strictly better than score y:
+func (p PdPayoff) Better(x int, y int) bool {
+    if p.Scoring == SCORE_POINTS {
+        return x > y
+    }
+    return x < y
+}
+
+// Returns true if mean score x is strictly better than mean score y:
+func (p PdPayoff) BetterMean(x float64, y float64) bool {
+    if p.Scoring == SCORE_POINTS {
+        return x > y
+    }
+    return x < y
+}
+
+// Returns a total payoff x as a gain, so that more is always better:
+func (p PdPayoff) Gain(x int) int {
+    if p.Scoring == SCORE_POINTS {
+        return x
+    }
+    return -x
+}
+
+func (p PdPayoff) ScoringName() string {
+    if p.Scoring == SCORE_POINTS {
+        return "points, higher is better"
+    }
+    return "years in prison, lower is better"
+}
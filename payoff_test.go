@@ -0,0 +1,81 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestPdPayoffValidate(t *testing.T) {
+    tests := []struct {
+        p PdPayoff
+        ok bool
+    }{
+        {DefaultPdPayoff(SCORE_YEARS), true},
+        {DefaultPdPayoff(SCORE_POINTS), true},
+        {PdPayoff{5, 3, 1, 0, SCORE_POINTS}, true},
+        {PdPayoff{0, 1, 2, 3, SCORE_YEARS}, true},
+        // The points payoffs read as years, and the other way round:
+        {PdPayoff{5, 3, 1, 0, SCORE_YEARS}, false},
+        {PdPayoff{0, 1, 2, 3, SCORE_POINTS}, false},
+        // T > R > P > S, but taking turns exploiting each other beats cooperating (2R <= T + S):
+        {PdPayoff{7, 3, 1, 0, SCORE_POINTS}, false},
+        {PdPayoff{6, 3, 1, 0, SCORE_POINTS}, false},
+        {PdPayoff{0, 4, 5, 7, SCORE_YEARS}, false},
+        // Two payoffs the same:
+        {PdPayoff{5, 5, 1, 0, SCORE_POINTS}, false},
+        {PdPayoff{0, 1, 1, 3, SCORE_YEARS}, false},
+        {PdPayoff{5, 3, 1, 0, 2}, false},
+    }
+    for _, test := range tests {
+        if err := test.p.Validate(); (err == nil) != test.ok {
+            t.Errorf("payoff %+v gives %v", test.p, err)
+        }
+    }
+}
+
+func TestPdPayoffBetter(t *testing.T) {
+    years, points := DefaultPdPayoff(SCORE_YEARS), DefaultPdPayoff(SCORE_POINTS)
+    tests := []struct {
+        p PdPayoff
+        x int
+        y int
+        better bool
+    }{
+        {years, 1, 2, true},
+        {years, 2, 1, false},
+        {years, 2, 2, false},
+        {points, 2, 1, true},
+        {points, 1, 2, false},
+        {points, 2, 2, false},
+    }
+    for _, test := range tests {
+        if b := test.p.Better(test.x, test.y); b != test.better {
+            t.Errorf("scoring %d: Better(%d, %d) = %v", test.p.Scoring, test.x, test.y, b)
+        }
+        if b := test.p.BetterMean(float64(test.x), float64(test.y)); b != test.better {
+            t.Errorf("scoring %d: BetterMean(%d, %d) = %v", test.p.Scoring, test.x, test.y, b)
+        }
+        // A gain is better the bigger it is, whatever the scoring:
+        if b := test.p.Gain(test.x) > test.p.Gain(test.y); b != test.better {
+            t.Errorf("scoring %d: Gain(%d) > Gain(%d) = %v", test.p.Scoring, test.x, test.y, b)
+        }
+    }
+
+    // Each player's payoffs, from either seat:
+    for _, p := range []PdPayoff{years, points} {
+        moves := [][4]int{
+            {COOPERATE, COOPERATE, p.Reward, p.Reward},
+            {COOPERATE, DEFECT, p.Suckers, p.Temptation},
+            {DEFECT, COOPERATE, p.Temptation, p.Suckers},
+            {DEFECT, DEFECT, p.Punishment, p.Punishment},
+        }
+        for _, m := range moves {
+            if a, b := p.Scores(m[0], m[1]); a != m[2] || b != m[3] {
+                t.Errorf("scoring %d: moves %d and %d score %d and %d, want %d and %d", p.Scoring, m[0], m[1], a, b, m[2], m[3])
+            }
+        }
+        // Defecting is better against either move, but mutual cooperation beats mutual defection:
+        if !p.Better(p.Temptation, p.Reward) || !p.Better(p.Punishment, p.Suckers) || !p.Better(p.Reward, p.Punishment) {
+            t.Errorf("scoring %d: the default payoffs aren't a dilemma", p.Scoring)
+        }
+    }
+}
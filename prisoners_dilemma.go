@@ -1,15 +1,201 @@
 package main
 
 import (
+    "encoding/json"
+    "errors"
     "fmt"
+    "math"
     "math/rand"
+    "strconv"
+    "sync/atomic"
+    "time"
 
     "github.com/prisoners_dilemma/cas"
     "github.com/prisoners_dilemma/lock"
-    "github.com/prisoners_dilemma/queue"
     "github.com/prisoners_dilemma/util"
 )
 
+// The parameters for a run of DiscoverPdRule():
+type DiscoverPdRuleParams struct {
+    Seed int64
+    CohortSize int
+    Squelch bool `json:"-"`
+    NumRounds int
+    DecisionDepth int
+    ResourceThreshold int
+    GenerationCap int
+    FitnessGoal int
+    MutationFrequency int
+    // How the mutation rate changes over the run (see MakeMutator()):
+    Mutation string
+    // The selection scheme used by Cohort.Evolve() (see MakeSelector()):
+    Selection string
+    // The crossover operator used by Cohort.Evolve() (see MakeCrossover()):
+    Crossover string
+    ControlSampleSize int
+    GamesPerGen int
+    // Where each member's opponents come from (see MakeOpponentPool()):
+    Opponents string
+    // Games the champion plays against each classic strategy (0 to skip):
+    ClassicGames int
+    Payoff PdPayoff
+    // Error rates for every game (see PdGameParams):
+    ActionNoise float64
+    PerceptionNoise float64
+    // "simultaneous" (the default) or "alternating" (see PdGameParams):
+    GameMode string
+    // "perspective" (the default) or "joint" (see PdGameParams):
+    Encoding string
+    // Allows payoffs which don't make a Prisoner's Dilemma:
+    AllowInvalidPayoff bool
+    // Receives a record of every generation, if not nil:
+    Stats StatsWriter `json:"-"`
+    // A checkpoint is written to CheckpointFile every CheckpointEvery generations:
+    CheckpointFile string `json:"-"`
+    CheckpointEvery int `json:"-"`
+    // If not nil, the run continues from this Cohort instead of a new one:
+    Resume *cas.Cohort `json:"-"`
+    // The state of the Mutator to resume with, as saved in a PdCheckpoint:
+    ResumeMutator json.RawMessage `json:"-"`
+    // The same for the OpponentPool:
+    ResumeOpponents json.RawMessage `json:"-"`
+    // The rounds played and the time taken before the run was resumed:
+    ResumeRounds int64 `json:"-"`
+    ResumeSeconds float64 `json:"-"`
+    // Closing Done stops the run at the end of the generation it is on:
+    Done <-chan struct{} `json:"-"`
+}
+
+// Returned by DiscoverPdRule() when its Done channel is closed:
+var ErrPdRunCanceled = errors.New("run canceled")
+
+func (p *DiscoverPdRuleParams) canceled() bool {
+    select {
+    case <-p.Done:
+        return true
+    default:
+        return false
+    }
+}
+
+// Returns the parameters for each game played during the run:
+func (p *DiscoverPdRuleParams) Game() PdGameParams {
+    mode, _ := pdGameMode(p.GameMode)
+    enc, _ := pdEncoding(p.Encoding)
+    return PdGameParams{
+        NumRounds: p.NumRounds,
+        DecisionDepth: p.DecisionDepth,
+        Payoff: p.Payoff,
+        ActionNoise: p.ActionNoise,
+        PerceptionNoise: p.PerceptionNoise,
+        Mode: mode,
+        Encoding: enc,
+    }
+}
+
+func pdGameMode(s string) (int, error) {
+    switch s {
+    case "", "simultaneous":
+        return SIMULTANEOUS, nil
+    case "alternating":
+        return ALTERNATING, nil
+    }
+    return SIMULTANEOUS, fmt.Errorf("unknown game mode %q (expected simultaneous or alternating)", s)
+}
+
+func pdEncoding(s string) (int, error) {
+    switch s {
+    case "", "perspective":
+        return PERSPECTIVE, nil
+    case "joint":
+        return JOINT, nil
+    }
+    return PERSPECTIVE, fmt.Errorf("unknown state encoding %q (expected perspective or joint)", s)
+}
+
+func pdEncodingName(enc int) string {
+    if enc == JOINT {
+        return "joint"
+    }
+    return "perspective"
+}
+
+func pdGameModeName(mode int) string {
+    if mode == ALTERNATING {
+        return "alternating"
+    }
+    return "simultaneous"
+}
+
+// Returns an error if the parameters can't make a sensible run:
+func (p *DiscoverPdRuleParams) Validate() error {
+    // The same ranges as the command line flags (see pdFlags), for runs which don't come from it:
+    ranges := []struct {
+        name string
+        v int
+        lo int
+        hi int
+    }{
+        {"decision depth", p.DecisionDepth, 1, DEPTH_CAP},
+        {"cohort size", p.CohortSize, 2, math.MaxInt32},
+        {"rounds per game", p.NumRounds, 1, math.MaxInt32},
+        {"resource threshold", p.ResourceThreshold, 0, math.MaxInt32},
+        {"generation cap", p.GenerationCap, 1, math.MaxInt32},
+        {"fitness goal", p.FitnessGoal, 0, 100},
+        {"mutation frequency", p.MutationFrequency, 1, math.MaxInt32},
+        {"games per generation", p.GamesPerGen, 1, math.MaxInt32},
+        {"control sample size", p.ControlSampleSize, 1, math.MaxInt32},
+        {"classic games", p.ClassicGames, 0, math.MaxInt32},
+    }
+    for _, x := range ranges {
+        if x.v < x.lo || x.v > x.hi {
+            return fmt.Errorf("%s %d is out of range: it must be %s", x.name, x.v, pdRangeText(float64(x.lo), float64(x.hi)))
+        }
+    }
+    if !p.AllowInvalidPayoff {
+        if err := p.Payoff.Validate(); err != nil {
+            return err
+        }
+    }
+    if _, err := MakeSelector(p.Selection, p.ResourceThreshold); err != nil {
+        return err
+    }
+    if _, err := MakeCrossover(p.Crossover); err != nil {
+        return err
+    }
+    if _, err := MakeMutator(p.Mutation, p.MutationFrequency, p.GenerationCap); err != nil {
+        return err
+    }
+    if _, err := MakeOpponentPool(p.Opponents); err != nil {
+        return err
+    }
+    if _, err := pdGameMode(p.GameMode); err != nil {
+        return err
+    }
+    if _, err := pdEncoding(p.Encoding); err != nil {
+        return err
+    }
+    if p.ActionNoise < 0 || p.ActionNoise > 1 {
+        return fmt.Errorf("action noise %g must be between 0 and 1", p.ActionNoise)
+    }
+    if p.PerceptionNoise < 0 || p.PerceptionNoise > 1 {
+        return fmt.Errorf("perception noise %g must be between 0 and 1", p.PerceptionNoise)
+    }
+    // A resumed Cohort must be the one the parameters describe:
+    if p.Resume != nil {
+        // A Cohort rounds an odd size up, so that its members can be paired off:
+        if n := p.CohortSize + p.CohortSize % 2; p.Resume.Size() != n {
+            return fmt.Errorf("the resumed Cohort has %d members, but the cohort size is %d", p.Resume.Size(), p.CohortSize)
+        }
+        for i := 0; i < p.Resume.Size(); i++ {
+            if d := p.Resume.Member(i).Depth(); d != p.DecisionDepth {
+                return fmt.Errorf("member %d of the resumed Cohort has depth %d, but the decision depth is %d", i, d, p.DecisionDepth)
+            }
+        }
+    }
+    return nil
+}
+
 type DiscoverPdRuleMetadata struct {
     DecisionDepth int
     CohortSize int
@@ -20,62 +206,129 @@ type DiscoverPdRuleMetadata struct {
     Seed int64
     GenerationsUsed int
     Rule []int
+    // The Rule in the portable string format (see cas.DecodeClassifier()):
+    EncodedRule string
     RuleWinPercent float64
+    // The percentage of games behind RuleWinPercent which were sampled rather than worked out (0 if it is exact):
+    RuleSampledPercent float64
+    // How the Rule fared against the classic strategies:
+    Classics []PdBenchmark
     MutationFrequency int
+    Mutation string
+    Selection string
+    Crossover string
     ControlSampleSize int
     GamesPerGen int
-    // TODO: Track time taken
+    Opponents string
+    Payoff PdPayoff
+    ActionNoise float64
+    PerceptionNoise float64
+    GameMode string
+    // The state encoding the Rule was evolved with, which it needs to be played the same way:
+    Encoding string
+    // What the run cost: rounds played while evolving, and wall-clock time:
+    RoundsPlayed int64
+    Seconds float64
 }
 
-func DiscoverPdRule(seed int64,
-                    cohortSize int,
-                    squelch bool,
-                    numRounds int,
-                    depth int,
-                    rThreshold int,
-                    genCap int,
-                    fitGoal int,
-                    mutationFrequency int,
-                    controlSampleSize int,
-                    gamesPerGen int) DiscoverPdRuleMetadata {
-    // Seed the PRNG (NOTE: currently does not result in step-for-step reproducibility)
-    if seed == USE_SYSTEM_TIME {
-        rand.Seed(seed)
-    } else {
-        rand.Seed(seed)
+/* Evolves a Cohort of Agents until it reaches the fitness goal (or the
+   generation cap), then picks its champion and tests it against random
+   samples. Returns an error without running anything if the parameters
+   are invalid.  */
+func DiscoverPdRule(p DiscoverPdRuleParams) (DiscoverPdRuleMetadata, error) {
+    if err := p.Validate(); err != nil {
+        return DiscoverPdRuleMetadata{}, err
     }
+    gp := p.Game()
+    sel, _ := MakeSelector(p.Selection, p.ResourceThreshold)
+    x, _ := MakeCrossover(p.Crossover)
+    m, _ := MakeMutator(p.Mutation, p.MutationFrequency, p.GenerationCap)
+    o, _ := MakeOpponentPool(p.Opponents)
+
+    /* Every stage of the run draws from its own PRNG stream derived from
+       the seed, and every goroutine is handed its own seed before it is
+       launched. The same seed and parameters therefore always reproduce
+       the same Cohort, champion and results, step-for-step.  */
 
-    // Make a Cohort: 
-    c := cas.MakeCohort(cohortSize, depth)
+    // Make a Cohort (or pick up an old one): 
+    var c cas.Cohort
+    if p.Resume != nil {
+        c = *p.Resume
+        if p.ResumeMutator != nil {
+            if err := json.Unmarshal(p.ResumeMutator, m); err != nil {
+                return DiscoverPdRuleMetadata{}, err
+            }
+        }
+        if p.ResumeOpponents != nil {
+            if err := json.Unmarshal(p.ResumeOpponents, o); err != nil {
+                return DiscoverPdRuleMetadata{}, err
+            }
+        }
+    } else {
+        c = cas.MakeCohort(p.CohortSize, p.DecisionDepth, util.MakeRand(util.DeriveSeed(p.Seed, -1)))
+    }
 
-    if !squelch {
+    if !p.Squelch {
         fmt.Println("Discovering Prisoner's Dilemma Rule...")
     }
 
-    // Process/Evolve Loop:
+    // Process/Evolve Loop (a resumed run goes on counting from where it was):
+    t := time.Now().Add(-time.Duration(p.ResumeSeconds * float64(time.Second)))
+    rounds := p.ResumeRounds
     for ;; {
-        if !squelch {
-            fmt.Printf("Generation %d / %d\n", c.Generation(), genCap - 1)
+        if !p.Squelch {
+            fmt.Printf("Generation %d / %d\n", c.Generation(), p.GenerationCap - 1)
         }
 
+        // Each generation has its own stream:
+        r := util.MakeRand(util.DeriveSeed(p.Seed, c.Generation()))
+
         // Process the generation:
-        pdGeneration(&c, numRounds, depth, gamesPerGen)
+        coop := pdGeneration(&c, gp, p.GamesPerGen, o, r)
+        rounds += int64(c.Size() * p.GamesPerGen * p.NumRounds)
+        st := pdGenerationStats(&c, coop)
 
         // Evolve the Cohort:
-        c.Evolve(rThreshold, c.Generation() + 1, mutationFrequency)
+        m.Observe(c.Generation(), c.Fitness())
+        st.Reproducers = c.Evolve(sel, x, m, c.Generation() + 1, r)
+        st.MutationRate = pdMutationRate(&c)
 
-        if !squelch {
+        if p.Stats != nil {
+            st.Seconds = time.Since(t).Seconds()
+            if err := p.Stats.Write(st); err != nil {
+                return DiscoverPdRuleMetadata{}, err
+            }
+        }
+
+        if p.CheckpointFile != "" && p.CheckpointEvery > 0 && c.Generation() % p.CheckpointEvery == 0 {
+            ms, err := json.Marshal(m)
+            var ob []byte
+            if err == nil {
+                ob, err = json.Marshal(o)
+            }
+            if err == nil {
+                err = SavePdCheckpoint(p.CheckpointFile, PdCheckpoint{p, &c, ms, ob, rounds, time.Since(t).Seconds()})
+            }
+            if err != nil {
+                return DiscoverPdRuleMetadata{}, err
+            }
+        }
+
+        if !p.Squelch {
             fmt.Printf("\tCohort Fitness: %.02f\n", c.Fitness())
         }
 
         // End simulation if goal reached or cap hit:
-        if c.Generation() >= genCap || c.Fitness() >= float64(fitGoal) {
+        if c.Generation() >= p.GenerationCap || c.Fitness() >= float64(p.FitnessGoal) {
             break
         }
+        if p.canceled() {
+            return DiscoverPdRuleMetadata{}, ErrPdRunCanceled
+        }
     }
 
     // Print the results for the Cohort:
-    if !squelch {
+    if !p.Squelch {
         fmt.Println("Cohort evolution complete!")
         fmt.Printf("\tCohort has %d members.\n", c.Metadata.Size)
         fmt.Printf("\tCohort ran for %d generations.\n", c.Metadata.Generation)
@@ -83,13 +336,14 @@ func DiscoverPdRule(seed int64,
     }
 
     // Find the best member of the Cohort:
-    if !squelch {
+    if !p.Squelch {
         fmt.Println("Finding champion...")
     }
-    v := pdChamp(&c, numRounds, depth)
+    r := util.MakeRand(util.DeriveSeed(p.Seed, c.Generation()))
+    v := pdChamp(&c, gp, r)
 
     // Print initial results:
-    if !squelch {
+    if !p.Squelch {
         fmt.Println("Champion found!")
         fmt.Printf("\tChampion ID #%d\n", v.Metadata.Id)
         fmt.Printf("\tChampion Generation: %d (age: %d)\n", v.Metadata.Generation, c.Generation() - v.Metadata.Generation - 1) 
@@ -104,137 +358,314 @@ func DiscoverPdRule(seed int64,
     }
 
     // Print final results:
-    if !squelch {
-        fmt.Printf("Testing Champion against %d random samples...\n", controlSampleSize)
+    if p.canceled() {
+        return DiscoverPdRuleMetadata{}, ErrPdRunCanceled
+    }
+    if !p.Squelch {
+        fmt.Printf("Testing Champion against random opponents...\n")
+    }
+    cr, cs := pdRuleWinPercent(v, gp, p.ControlSampleSize, p.Squelch, r)
+    if !p.Squelch {
+        fmt.Printf("\tChampion win/loss percentage vs. random opponents: %.02f (%s)\n", cr, pdSampledString(cs))
     }
-    cr := pdTestAgentAgainstSamples(v, numRounds, depth, controlSampleSize, squelch)
-    if !squelch {
-        fmt.Printf("\tChampion win/loss percentage vs. random samples: %.02f\n", cr)
+    var cb []PdBenchmark
+    if p.ClassicGames > 0 {
+        if !p.Squelch {
+            fmt.Printf("Testing Champion against classic strategies...\n")
+        }
+        cb = pdTestAgentAgainstClassics(v, gp, p.ClassicGames, r)
     }
 
     // Collect and return metadata:
     md := DiscoverPdRuleMetadata{}
-    md.DecisionDepth = depth
-    md.CohortSize = cohortSize
-    md.NumRounds = numRounds
-    md.ResourceThreshold = rThreshold
-    md.GenerationCap = genCap
-    md.FitnessGoal = fitGoal
-    md.Seed = seed
+    md.DecisionDepth = p.DecisionDepth
+    md.CohortSize = p.CohortSize
+    md.NumRounds = p.NumRounds
+    md.ResourceThreshold = p.ResourceThreshold
+    md.GenerationCap = p.GenerationCap
+    md.FitnessGoal = p.FitnessGoal
+    md.Seed = p.Seed
     md.GenerationsUsed = c.Generation()
     md.Rule = v.Rule()
+    md.EncodedRule = v.Encode()
     md.RuleWinPercent = cr
-    md.MutationFrequency = mutationFrequency 
-    md.ControlSampleSize = controlSampleSize
-    md.GamesPerGen = gamesPerGen
-    return md
+    md.RuleSampledPercent = cs
+    md.Classics = cb
+    md.MutationFrequency = p.MutationFrequency 
+    md.Mutation = m.Name()
+    md.Selection = sel.Name()
+    md.Crossover = x.Name()
+    md.ControlSampleSize = p.ControlSampleSize
+    md.GamesPerGen = p.GamesPerGen
+    md.Opponents = o.Name()
+    md.Payoff = p.Payoff
+    md.ActionNoise = p.ActionNoise
+    md.PerceptionNoise = p.PerceptionNoise
+    md.GameMode = pdGameModeName(gp.Mode)
+    md.Encoding = pdEncodingName(gp.Encoding)
+    md.RoundsPlayed = rounds
+    md.Seconds = time.Since(t).Seconds()
+    return md, nil
 }
 
 /* Tests an Agent against a given number of random Agents (preferably a very large
 number) to get a good idea of what its general effectiveness is as a Prisoner's
 Dilemma Classifier Rule.  */
 func pdTestAgentAgainstSamples(a *cas.Agent, 
-                               rounds int, 
-                               depth int, 
+                               gp PdGameParams, 
                                samples int, 
-                               squelch bool) float64 {
-    cw, cl := 0, 0 
-    lk := lock.MakeLock(samples)
-    lk.ToggleAllBusy()
-    cur := 0
-    max := GOROUTINE_CAP
-    for i := 0; i < samples; {
-        if cur < max {
-            if !squelch {
-                x := util.Percent(float64(i), float64(samples))
-                fmt.Printf("\tSampling is %.02f percent finished.\n", x)
-            }
-            cur++
-            go func(k int) {
-                b := cas.MakeAgent(depth)
-                w := pdGame(a, &b, rounds, false, depth)
-                if w == a {
-                    cw++
-                } else {
-                    cl++
-                }
-                cur--
-                lk.ToggleFinished(k)
-            }(i)
-            i++
+                               squelch bool,
+                               r *rand.Rand) float64 {
+    var cw, cl int64
+    pl := lock.MakePool(GOROUTINE_CAP)
+    for i := 0; i < samples; i++ {
+        if !squelch {
+            x := util.Percent(float64(i), float64(samples))
+            fmt.Printf("\tSampling is %.02f percent finished.\n", x)
         }
+        s := r.Int63()
+        pl.Go(func() {
+            g := util.MakeRand(s)
+            b := cas.MakeAgent(gp.DecisionDepth, g)
+            w := pdGame(a, &b, gp, false, g).Winner
+            if w == a {
+                atomic.AddInt64(&cw, 1)
+            } else {
+                atomic.AddInt64(&cl, 1)
+            }
+        })
     }
-    lk.ConcurrentJoin()
+    pl.Join()
     return util.Percent(float64(cw), float64(cw + cl))
 }
 
-// Plays a game of Prisoner's Dilemma and returns a pointer to the winner:
-func pdGame(a *cas.Agent, b *cas.Agent, rounds int, counts bool, depth int) *cas.Agent { 
-    // Random player goes first:              
+// How an Agent fared against one of the classic strategies:
+type PdBenchmark struct {
+    Opponent string
+    Games int
+    Wins int
+    Ties int
+    // Mean payoff per round to the Agent and to the classic strategy:
+    Score float64
+    OpponentScore float64
+}
+
+/* Plays an Agent against each of the classic strategies in the cas
+   package, a given number of games apiece, to see how it fares against
+   sensible play rather than random noise.  */
+func pdTestAgentAgainstClassics(a *cas.Agent, 
+                                gp PdGameParams, 
+                                games int, 
+                                r *rand.Rand) []PdBenchmark {
+    n := cas.ClassicStrategies()
+    res := make([]PdBenchmark, len(n))
+    pl := lock.MakePool(GOROUTINE_CAP)
+    for i := range n {
+        k, s := i, r.Int63()
+        pl.Go(func() {
+            g := util.MakeRand(s)
+            b, _ := MakeClassicAgent(n[k], gp.Encoding)
+            x := PdBenchmark{Opponent: n[k], Games: games}
+            sa, sb := 0, 0
+            for j := 0; j < games; j++ {
+                y := pdGame(a, &b, gp, false, g)
+                if y.Tie {
+                    x.Ties++
+                } else if y.Winner == a {
+                    x.Wins++
+                }
+                sa += y.ScoreA
+                sb += y.ScoreB
+            }
+            t := float64(games * gp.NumRounds)
+            x.Score, x.OpponentScore = float64(sa) / t, float64(sb) / t
+            res[k] = x
+        })
+    }
+    pl.Join()
+    return res
+}
+
+// Everything which decides how a single game of Prisoner's Dilemma is played:
+type PdGameParams struct {
+    NumRounds int
+    DecisionDepth int
+    Payoff PdPayoff
+    // Chance that a move comes out as the opposite of the one intended:
+    ActionNoise float64
+    // Chance that a move is recorded wrongly in the opponent's memory:
+    PerceptionNoise float64
+    /* SIMULTANEOUS, where both players choose their moves each round
+       from the state at the start of the round, or ALTERNATING, where
+       the second player to move sees the first player's move of that
+       round in its state.  */
+    Mode int
+    /* PERSPECTIVE, where a Classifier's state is its own last moves
+       followed by its opponent's, so that a rule means the same thing
+       from either seat, or JOINT, where the state is player a's last
+       moves followed by player b's whichever player is moving, so that
+       player b reads its own moves where player a reads its opponent's.  */
+    Encoding int
+    // Record every move, in PdGameResult.MovesA and MovesB:
+    Trace bool
+}
+
+// The outcome of a game of Prisoner's Dilemma:
+type PdGameResult struct {
+    Winner *cas.Agent
+    Tie bool
+    ScoreA int
+    ScoreB int
+    // How many times each player cooperated:
+    CooperationsA int
+    CooperationsB int
+    // Whether a moved first each round (in the alternating game):
+    FirstA bool
+    // Every move each player made, if the game was traced:
+    MovesA []int
+    MovesB []int
+}
+
+/* One player's memory of a game: the moves of player a and of player b as
+   that player saw them. Without perception noise both players see the
+   same thing, and share a single view.  */
+type pdView struct {
+    h cas.History
+    // Full histories, kept only for Agents with a native Strategy:
+    full bool
+    ha []int
+    hb []int
+}
+
+/* A cas.History holds the turn memory, with player a as player 0. It
+   starts full of zeros, which is the same as if both players had
+   cooperated for depth rounds in a row.  */
+func makePdView(depth int, full bool) *pdView {
+    return &pdView{h: cas.MakeHistory(depth), full: full}
+}
+
+// Records a move by player a (or by player b, if a is false):
+func (v *pdView) record(a bool, m int) {
+    p, h := 0, &v.ha
+    if !a {
+        p, h = 1, &v.hb
+    }
+    v.h.Record(p, m)
+    if v.full {
+        *h = append(*h, m)
+    }
+}
+
+/* Returns the index of the game state as seen by a Classifier of depth d
+   moving as player a (or as player b, if a is false), which is the last d
+   moves of each player in the order of the encoding enc. This is the
+   whole of the History unless the game is being played between
+   Classifiers of different depths.  */
+func (v *pdView) state(d int, a bool, enc int) int {
+    return pdStateIndex(&v.h, d, a, enc)
+}
+
+func pdStateIndex(h *cas.History, d int, a bool, enc int) int {
+    if !a && enc == PERSPECTIVE {
+        return h.Index(d, 1)
+    }
+    return h.Index(d, 0)
+}
+
+/* Plays a game of Prisoner's Dilemma and returns the result. The winner
+   is whoever has the better score under the payoff's scoring convention,
+   and ties go to b.  */
+func pdGame(a *cas.Agent, b *cas.Agent, gp PdGameParams, counts bool, r *rand.Rand) PdGameResult { 
+    res := PdGameResult{}
+
+    // The History must be deep enough for the deeper of the two Classifiers:
+    depth := gp.DecisionDepth
+    if a.Depth() > depth {
+        depth = a.Depth()
+    }
+    if b.Depth() > depth {
+        depth = b.Depth()
+    }
+
+    // Random player goes first (which only matters in the alternating game):
     p := []*cas.Agent{a, b}
-    t := rand.Intn(2)
+    t := r.Intn(2)
+    res.FirstA = t == 0
 
     // Cumulative "points":
     sa, sb := 0, 0
 
-    // Queues are used to hold turn memory:
-    qa, qb := queue.MakeQueue(depth), queue.MakeQueue(depth)
-    for i := 0; i < depth; i++ {
-        qa.Insert(0)
-        qb.Insert(0)
+    // Each player's memory of the game (Agents with a native Strategy see the whole history):
+    full := a.Strategy() != nil || b.Strategy() != nil
+    vs := []*pdView{makePdView(depth, full), nil}
+    vs[1] = vs[0]
+    if gp.PerceptionNoise > 0 {
+        vs[1] = makePdView(depth, full)
+    }
+
+    /* Games between Classifiers without noise are worked out from the
+       cycle they fall into instead (see pdExactGame()), unless every
+       move is wanted. They draw nothing more from r either way.  */
+    n := gp.NumRounds
+    if !gp.Trace && pdIsDeterministic(a, b, gp) {
+        x := pdExactGame(a, b, gp, depth, t)
+        sa, sb, res.CooperationsA, res.CooperationsB = x.sa, x.sb, x.ca, x.cb
+        n = 0
     }
 
     // Players face off for n rounds:
-    for i := 0; i < rounds; i++ {
+    for i := 0; i < n; i++ {
 
         /* NOTE: One could also randomize the turn order each round.
            That could make a difference for some Classifiers. I will
            experiment with that down the road.  */
 
         // Player decision/score this round:
-        var ra, rb int 
+        var m [2]int
 
         // Each player takes a turn each round:
         for j := 0; j < 2; j++ {
             
-            /* NOTE: The queues' contents are combined in to a slice of 1s
-               and 0s which the Agents' Classifiers will treat as a 
-               binary number. The default state {0, 0, 0, 0, 0, 0}
+            /* NOTE: The History's contents are kept as the bits of a
+               binary number which the Agents' Classifiers use as the
+               index of their move. The default state {0, 0, 0, 0, 0, 0}
                is the same as if both players had cooperated for 3
                rounds in a row. This is just a starting point based on
                John Holland's paper. You could use many more rounds of 
                depth for this, up to the practical limits of computation.  */
-            s := append(qa.Contents(), qb.Contents() ...)
-           
-            // Current player makes a decision to COOPERATE or DEFECT:
-            if t == 0 {
-                ra = p[t].CalcMove(s) 
-                qa.Del()
-                qa.Insert(ra)
-            } else {
-                rb = p[t].CalcMove(s)
-                qb.Del()
-                qb.Insert(rb)
+            m[t] = pdDecide(p[t], vs[t], t, gp, r)
+
+            // In the alternating game the second mover sees the first move:
+            if gp.Mode == ALTERNATING {
+                pdRecord(vs, t, m[t], gp, r)
             }
             t = (t + 1) % 2
         }
 
+        // In the simultaneous game neither move is seen until both are made:
+        if gp.Mode == SIMULTANEOUS {
+            pdRecord(vs, 0, m[0], gp, r)
+            pdRecord(vs, 1, m[1], gp, r)
+        }
+        ra, rb := m[0], m[1]
+
         // Tally points: 
-        if ra == COOPERATE && rb == COOPERATE {
-            ra, rb = REWARD, REWARD
-        } else if ra == COOPERATE && rb == DEFECT {
-            ra, rb = SUCKERS, TEMPTATION
-        } else if ra == DEFECT && rb == COOPERATE {
-            ra, rb = TEMPTATION, SUCKERS
-        } else {
-            ra, rb = PUNISHMENT, PUNISHMENT
+        if gp.Trace {
+            res.MovesA, res.MovesB = append(res.MovesA, ra), append(res.MovesB, rb)
+        }
+        if ra == COOPERATE {
+            res.CooperationsA++
         }
+        if rb == COOPERATE {
+            res.CooperationsB++
+        }
+        ra, rb = gp.Payoff.Scores(ra, rb)
         sa += ra
         sb += rb
     }
-    // Award resources to the one who got the least points:
+    // Award resources to the one with the better score:
     var w *cas.Agent
-    if sa < sb {
+    if gp.Payoff.Better(sa, sb) {
         w = a
         if counts {
             a.Metadata.Wins++
@@ -256,93 +687,151 @@ func pdGame(a *cas.Agent, b *cas.Agent, rounds int, counts bool, depth int) *cas
         y, z := u.Metadata.Wins, u.Metadata.Losses
         u.Metadata.WinRate = util.Percent(float64(y), float64(y + z))
     }
-    f(a)
-    f(b)
-    return w
+    if counts {
+        f(a)
+        f(b)
+    }
+    res.Winner = w
+    res.Tie = sa == sb
+    res.ScoreA, res.ScoreB = sa, sb
+    return res
+}
+
+/* Asks player t (0 for a, 1 for b) to make a decision to COOPERATE or
+   DEFECT, from the game as it sees it in its view v.  */
+func pdDecide(a *cas.Agent, v *pdView, t int, gp PdGameParams, r *rand.Rand) int {
+    own, opp := v.ha, v.hb
+    if t == 1 {
+        own, opp = v.hb, v.ha
+    }
+    m := pdMove(a, v.state(a.Depth(), t == 0, gp.Encoding), own, opp, r)
+
+    // A trembling hand may make the opposite move to the one intended:
+    if gp.ActionNoise > 0 && r.Float64() < gp.ActionNoise {
+        m = 1 - m
+    }
+    return m
+}
+
+/* Records player t's move m in both players' views. The mover knows its
+   own move, but the opponent may misperceive it.  */
+func pdRecord(vs []*pdView, t int, m int, gp PdGameParams, r *rand.Rand) {
+    v := vs[t]
+    v.record(t == 0, m)
+    if w := vs[1 - t]; w != v {
+        if r.Float64() < gp.PerceptionNoise {
+            m = 1 - m
+        }
+        w.record(t == 0, m)
+    }
 }
 
-/* Runs the Cohort through a "generation". This involves
-   nested concurrency, as each Agent in the cohort plays
-   multiple randomly generated Agents each generation. */
+/* Asks an Agent for its move. Classifier Agents read the index s of the
+   game state, and Agents with a native Strategy read the full histories
+   of their own and their opponent's moves.  */
+func pdMove(a *cas.Agent, s int, own []int, opp []int, r *rand.Rand) int {
+    if st := a.Strategy(); st != nil {
+        return st.CalcMove(own, opp, r)
+    }
+    return a.CalcMoveIndex(s)
+}
+
+/* Runs the Cohort through a "generation". Each Agent in the cohort
+   plays multiple opponents from the pool o each generation,
+   concurrently with the other members, and the Cohort's fitness is the
+   percentage of those games its members won. Each opponent is a copy,
+   so only the member's side of the game is counted. A member's games
+   are played in order from its own seeded stream, so the result doesn't
+   depend on how the goroutines are scheduled. Against a pool which
+   scores by payoff (see pdScoresByPayoff()), a member wins a game by
+   scoring better than halfway from mutual defection to mutual
+   cooperation, whatever the opponent scores. Returns the percentage of
+   the members' moves which were to cooperate.  */
 func pdGeneration(c *cas.Cohort, 
-                  rounds int, 
-                  depth int, 
-                  gamesPerGeneration int) {
-    c.Lock.ToggleAllBusy()
+                  gp PdGameParams, 
+                  gamesPerGeneration int,
+                  o OpponentPool,
+                  r *rand.Rand) float64 {
+    o.Prepare(c)
+    byPayoff := pdScoresByPayoff(o)
     f := make([]float64, c.Size())
-    cur := 0
-    max := GOROUTINE_CAP
-    for i := 0; i < c.Size(); { 
-        if cur < max {
-            cur += gamesPerGeneration
-            go func(j int) {
-                lk := lock.MakeLock(gamesPerGeneration)
-                lk.ToggleAllBusy()
-                p := 0
-                for k := 0; k < lk.Size(); k++ {
-                    go func(h int) { 
-                        a, b := c.Member(j), cas.MakeAgent(depth)
-                        w := pdGame(a, &b, rounds, true, depth) 
-                        if *w == *a {
-                            p++
-                        }
-                        lk.ToggleFinished(h)
-                    }(k) 
+    h := make([]int, c.Size())
+    pl := lock.MakePool(GOROUTINE_CAP)
+    for i := 0; i < c.Size(); i++ { 
+        j, s := i, r.Int63()
+        pl.Go(func() {
+            g := util.MakeRand(s)
+            p := 0
+            for k := 0; k < gamesPerGeneration; k++ {
+                a, b := c.Member(j), o.Opponent(j, gp, g)
+                x := pdGame(a, &b, gp, !byPayoff, g)
+                won := x.Winner == a
+                if byPayoff {
+                    won = gp.Payoff.Better(2 * x.ScoreA, gp.NumRounds * (gp.Payoff.Reward + gp.Payoff.Punishment))
+                    pdAward(a, won)
                 }
-                lk.ConcurrentJoin()
-                f[j] = float64(p)
-                cur -= gamesPerGeneration
-                c.Lock.ToggleFinished(j)
-            }(i)
-            i++
-        }
+                if won {
+                    p++
+                }
+                h[j] += x.CooperationsA
+            }
+            f[j] = float64(p)
+        })
     } 
-    c.Lock.ConcurrentJoin()
+    pl.Join()
+    o.Observe(c)
     // Calculate fitness for current generation:
     s := 0.0
     for i := range f { 
         s += f[i]
     }
     c.SetFitness(util.Percent(s, float64(len(f) * gamesPerGeneration)))
+    n := 0
+    for i := range h {
+        n += h[i]
+    }
+    return util.Percent(float64(n), float64(len(h) * gamesPerGeneration * gp.NumRounds))
+}
+
+// Counts a game of a member's which was scored by payoff, as pdGame() counts the rest:
+func pdAward(a *cas.Agent, won bool) {
+    if won {
+        a.Metadata.Wins++
+        a.AddResources(1)
+        a.Metadata.Resources++
+    } else {
+        a.Metadata.Losses++
+    }
+    y, z := a.Metadata.Wins, a.Metadata.Losses
+    a.Metadata.WinRate = util.Percent(float64(y), float64(y + z))
 }
 
-/* To find the champ, each member of the Cohort plays each other member of
-the Cohort (including themselves), and the winner is the one with the
-most wins.  */
+/* To find the champ, the Cohort plays a round-robin tournament (see
+RunTournament()) in which each pair of members plays twice and each
+member also plays itself, and the winner is the one with the most wins
+(or the best score, between members with the same number of wins).  */
 func pdChamp(c *cas.Cohort, 
-             rounds int, 
-             depth int) *cas.Agent {
-    r := make([]int, c.Size()) 
-    c.Lock.ToggleAllBusy()
-    cur := 0
-    max := GOROUTINE_CAP
-    for i := 0; i < len(r); {
-        if cur < max {
-            cur++
-            go func(k int) {
-                a := c.Member(k)
-                for j := range r {
-                    b := c.Member(j)
-                    w := pdGame(a, b, rounds, false, depth)
-                    if w.Id() == a.Id() {
-                        r[k]++
-                    }
-                }
-                c.Lock.ToggleFinished(k)
-            }(i)
-            cur--
-            i++
-        }
+             gp PdGameParams,
+             r *rand.Rand) *cas.Agent {
+    e := make([]*cas.Agent, c.Size())
+    for i := range e {
+        e[i] = c.Member(i)
     }
-    c.Lock.ConcurrentJoin()
-    var v *cas.Agent
-    m := 0
-    for i := range r {
-        if r[i] > m {
-            m = r[i]
-            v = c.Member(i)
+    return pdBestOf(e, gp, r)
+}
+
+// Picks the best of some Agents in the same way as pdChamp():
+func pdBestOf(e []*cas.Agent, gp PdGameParams, r *rand.Rand) *cas.Agent {
+    names := make([]string, len(e))
+    for i := range e {
+        names[i] = strconv.Itoa(e[i].Id())
+    }
+    res := RunTournament(e, names, gp, 2, true, r)
+    v := res.Standings[0]
+    for _, x := range res.Standings {
+        if x.Wins > v.Wins {
+            v = x
         }
     }
-    return v
+    return e[v.Entrant]
 }
-
@@ -0,0 +1,240 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "strconv"
+    "strings"
+    "testing"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/util"
+)
+
+// Returns the parameters discover would run with, given its flags:
+func pdTestParams(t *testing.T, args ...string) DiscoverPdRuleParams {
+    f := makePdFlags("discover", "", "")
+    f.game()
+    f.evolution()
+    f.testing()
+    if _, err := f.parse(args, 0); err != nil {
+        t.Fatal(err)
+    }
+    p := f.params()
+    p.Squelch = true
+    return p
+}
+
+// A Cohort can only be resumed by parameters which describe it:
+func TestPdResumeMismatch(t *testing.T) {
+    r := util.MakeRand(6)
+    tests := []struct {
+        cohortSize int
+        size int
+        depth int
+        ok bool
+    }{
+        {4, 4, 2, true},
+        // An odd cohort size is rounded up:
+        {3, 4, 2, true},
+        {4, 6, 2, false},
+        {6, 4, 2, false},
+        {4, 4, 1, false},
+        {4, 4, 3, false},
+    }
+    for _, test := range tests {
+        p := pdTestParams(t, fmt.Sprintf("-cohortSize=%d", test.cohortSize), "-decisionDepth=2", "-genCap=1")
+        c := cas.MakeCohort(test.size, test.depth, r)
+        p.Resume = &c
+        if err := p.Validate(); (err == nil) != test.ok {
+            t.Errorf("resuming %d members of depth %d into a cohort of %d at depth 2 gives %v", test.size, test.depth, test.cohortSize, err)
+        }
+        if _, err := DiscoverPdRule(p); !test.ok && err == nil {
+            t.Errorf("a run resumed %d members of depth %d into a cohort of %d at depth 2", test.size, test.depth, test.cohortSize)
+        }
+    }
+}
+
+// Returns a Cohort of n members which all play the same depth 1 rule:
+func pdTestUniformCohort(t *testing.T, n int, rule string) cas.Cohort {
+    m := make([]string, n)
+    for i := range m {
+        m[i] = fmt.Sprintf(`{"id":%d,"classifier":{"depth":1,"rule":[%s]}}`, i, rule)
+    }
+    c := cas.Cohort{}
+    if err := json.Unmarshal([]byte(`{"next_id":` + strconv.Itoa(n) + `,"members":[` + strings.Join(m, ",") + `]}`), &c); err != nil {
+        t.Fatal(err)
+    }
+    return c
+}
+
+// Against copies of itself, a Cohort is scored by payoff, so cooperating wins and defecting doesn't:
+func TestPdGenerationByPayoff(t *testing.T) {
+    for _, spec := range []string{"cohort", "hall"} {
+        for scoring := 0; scoring < 2; scoring++ {
+            gp := PdGameParams{NumRounds: 20, DecisionDepth: 1, Payoff: DefaultPdPayoff(scoring)}
+            for _, x := range []struct {
+                rule string
+                fitness float64
+            }{{"0", 100}, {"15", 0}} {
+                c := pdTestUniformCohort(t, 6, x.rule)
+                o, _ := MakeOpponentPool(spec)
+                // Fill the hall of fame with the Cohort's own rule:
+                o.Observe(&c)
+                pdGeneration(&c, gp, 10, o, util.MakeRand(1))
+                if c.Fitness() != x.fitness {
+                    t.Errorf("a Cohort of rule %s against %s (scoring %d) has fitness %g, want %g", x.rule, spec, scoring, c.Fitness(), x.fitness)
+                }
+                if w := c.Member(0).Resources(); w != int(x.fitness) / 10 {
+                    t.Errorf("a member of a Cohort of rule %s against %s (scoring %d) won %d resources, want %d", x.rule, spec, scoring, w, int(x.fitness) / 10)
+                }
+            }
+        }
+    }
+}
+
+// Returns a classic strategy as played under the encoding:
+func pdTestClassic(t *testing.T, name string, enc int) *cas.Agent {
+    a, err := MakeClassicAgent(name, enc)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return &a
+}
+
+// Returns n copies of the move m:
+func pdTestMoves(n int, m int) []int {
+    x := make([]int, n)
+    for i := range x {
+        x[i] = m
+    }
+    return x
+}
+
+// With a noise of 1, every move comes out, or is seen, as the opposite:
+func TestPdGameNoise(t *testing.T) {
+    const n = 12
+    for mode := 0; mode < 2; mode++ {
+        for enc := 0; enc < 2; enc++ {
+            gp := PdGameParams{NumRounds: n, DecisionDepth: 1, Payoff: DefaultPdPayoff(SCORE_POINTS), Mode: mode, Encoding: enc, Trace: true}
+            allc, alld, tft := pdTestClassic(t, "allc", enc), pdTestClassic(t, "alld", enc), pdTestClassic(t, "tft", enc)
+
+            gp.ActionNoise = 1
+            x := pdGame(allc, alld, gp, false, util.MakeRand(1))
+            if !reflect.DeepEqual(x.MovesA, pdTestMoves(n, DEFECT)) || !reflect.DeepEqual(x.MovesB, pdTestMoves(n, COOPERATE)) {
+                t.Errorf("mode %d, encoding %d: with action noise 1, allc and alld play %v and %v", mode, enc, x.MovesA, x.MovesB)
+            }
+            if x.CooperationsA != 0 || x.CooperationsB != n || x.ScoreA != n * gp.Payoff.Temptation {
+                t.Errorf("mode %d, encoding %d: with action noise 1, allc and alld are scored as %+v", mode, enc, x)
+            }
+
+            /* With perception noise tft sees allc defect, and so defects
+               itself from its second move on, but allc's own moves are
+               recorded as they were made.  */
+            gp.ActionNoise, gp.PerceptionNoise = 0, 1
+            x = pdGame(tft, allc, gp, false, util.MakeRand(2))
+            want := pdTestMoves(n, DEFECT)
+            want[0] = COOPERATE
+            if mode == ALTERNATING && !x.FirstA {
+                // Moving second, tft already sees allc's first move:
+                want[0] = DEFECT
+            }
+            if !reflect.DeepEqual(x.MovesA, want) || !reflect.DeepEqual(x.MovesB, pdTestMoves(n, COOPERATE)) {
+                t.Errorf("mode %d, encoding %d: with perception noise 1, tft and allc play %v and %v", mode, enc, x.MovesA, x.MovesB)
+            }
+        }
+    }
+}
+
+// In the simultaneous game neither player sees the other's move until the round is over:
+func TestPdGameMode(t *testing.T) {
+    const n = 6
+    for enc := 0; enc < 2; enc++ {
+        tft, alld := pdTestClassic(t, "tft", enc), pdTestClassic(t, "alld", enc)
+        // Whether tft has moved second, and first, in each mode:
+        seen := [2][2]bool{}
+        for k := 0; k < 40; k++ {
+            for _, mode := range []int{SIMULTANEOUS, ALTERNATING} {
+                gp := PdGameParams{NumRounds: n, DecisionDepth: 1, Payoff: DefaultPdPayoff(SCORE_POINTS), Mode: mode, Encoding: enc, Trace: true}
+                x := pdGame(tft, alld, gp, false, util.MakeRand(int64(k)))
+                // tft only answers alld's first defection on its first move if it moves second and sees it:
+                want := pdTestMoves(n, DEFECT)
+                if mode == SIMULTANEOUS || x.FirstA {
+                    want[0] = COOPERATE
+                }
+                if !reflect.DeepEqual(x.MovesA, want) {
+                    t.Fatalf("encoding %d, mode %d, tft moving first %v: tft plays %v against alld, want %v", enc, mode, x.FirstA, x.MovesA, want)
+                }
+                if x.FirstA {
+                    seen[mode][1] = true
+                } else {
+                    seen[mode][0] = true
+                }
+            }
+        }
+        if seen != [2][2]bool{{true, true}, {true, true}} {
+            t.Fatalf("tft didn't move both first and second in both modes: %v", seen)
+        }
+    }
+}
+
+func TestPdStateIndex(t *testing.T) {
+    // a has played D then C, and b C then D:
+    h := cas.MakeHistory(2)
+    h.Record(0, DEFECT)
+    h.Record(1, COOPERATE)
+    h.Record(0, COOPERATE)
+    h.Record(1, DEFECT)
+    tests := []struct {
+        d int
+        a bool
+        enc int
+        want int
+    }{
+        // Own moves are the low bits under PERSPECTIVE, and a's are under JOINT:
+        {2, true, PERSPECTIVE, 0b10_01},
+        {2, false, PERSPECTIVE, 0b01_10},
+        {2, true, JOINT, 0b10_01},
+        {2, false, JOINT, 0b10_01},
+        // A shallower rule sees only the latest moves:
+        {1, true, PERSPECTIVE, 0b1_0},
+        {1, false, PERSPECTIVE, 0b0_1},
+        {1, false, JOINT, 0b1_0},
+    }
+    for _, test := range tests {
+        if x := pdStateIndex(&h, test.d, test.a, test.enc); x != test.want {
+            t.Errorf("depth %d, seat a %v, encoding %d: index %b, want %b", test.d, test.a, test.enc, x, test.want)
+        }
+    }
+}
+
+// Under PERSPECTIVE a rule plays the same from either seat:
+func TestPdGamePerspective(t *testing.T) {
+    const n = 10
+    gp := PdGameParams{NumRounds: n, DecisionDepth: 2, Payoff: DefaultPdPayoff(SCORE_POINTS), Encoding: PERSPECTIVE, Trace: true}
+    tft, alld := pdTestClassic(t, "tft", PERSPECTIVE), pdTestClassic(t, "alld", PERSPECTIVE)
+    x, y := pdGame(tft, alld, gp, false, util.MakeRand(1)), pdGame(alld, tft, gp, false, util.MakeRand(1))
+    want := pdTestMoves(n, DEFECT)
+    want[0] = COOPERATE
+    if !reflect.DeepEqual(x.MovesA, want) || !reflect.DeepEqual(y.MovesB, want) {
+        t.Fatalf("tft plays %v from seat a and %v from seat b against alld, want %v", x.MovesA, y.MovesB, want)
+    }
+
+    // Any two rules, in simultaneous games, which don't depend on who moves first:
+    r := util.MakeRand(22)
+    for k := 0; k < 200; k++ {
+        a, b := cas.MakeAgent(1 + r.Intn(3), r), cas.MakeAgent(1 + r.Intn(3), r)
+        x, y := pdGame(&a, &b, gp, false, r), pdGame(&b, &a, gp, false, r)
+        if !reflect.DeepEqual(x.MovesA, y.MovesB) || !reflect.DeepEqual(x.MovesB, y.MovesA) {
+            t.Fatalf("rules %s and %s play differently when they swap seats", a.Encode(), b.Encode())
+        }
+    }
+
+    // Under JOINT the same rule in seat b reads its own moves as its opponent's, so it copies itself:
+    gp.Encoding = JOINT
+    y = pdGame(alld, tft, gp, false, util.MakeRand(1))
+    if !reflect.DeepEqual(y.MovesB, pdTestMoves(n, COOPERATE)) {
+        t.Fatalf("under JOINT, the perspective tft rule plays %v from seat b against alld", y.MovesB)
+    }
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "image"
+    "image/color"
+    "image/png"
+    "io"
+    "os"
+    "strings"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/util"
+)
+
+/* A rule's state is the last d moves of one player followed by the last
+   d moves of the other, oldest first, read as a binary number with the
+   first element as the lowest bit (see cas.Classifier.Index()). So the
+   low d bits of an entry's index are the first history and the high d
+   bits are the second, and within each half the most recent move is the
+   highest bit. Under the PERSPECTIVE encoding the first history is always
+   the rule's own, and under the JOINT encoding it is player a's, so the
+   drawings show how the rule plays from either seat, or from seat a.  */
+
+var (
+    pdCooperateColor = color.RGBA{0x2c, 0xa2, 0x5f, 0xff}
+    pdDefectColor = color.RGBA{0xc0, 0x39, 0x2b, 0xff}
+    pdGridColor = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// Returns a history of d moves, oldest first, as a string of Cs and Ds:
+func pdHistoryString(h int, d int) string {
+    var b strings.Builder
+    for i := 0; i < d; i++ {
+        if h >> uint(i) & 1 == COOPERATE {
+            b.WriteByte('C')
+        } else {
+            b.WriteByte('D')
+        }
+    }
+    return b.String()
+}
+
+func pdMoveString(m int) string {
+    if m == COOPERATE {
+        return "C"
+    }
+    return "D"
+}
+
+func pdRenderableRule(a *cas.Agent) ([]int, int, error) {
+    if a.Strategy() != nil {
+        return nil, 0, fmt.Errorf("%s is a native strategy, not a Classifier rule, so it can't be drawn", a.Name())
+    }
+    return a.Rule(), a.Depth(), nil
+}
+
+/* Draws a rule as a heatmap: a 2^d by 2^d grid in which the column is
+   own history and the row is the opponent's, and each cell is green if
+   the rule cooperates in that state and red if it defects. Histories are
+   ordered by their most recent move first, so the left half of the image
+   is where the rule cooperated last and the top half is where the
+   opponent did.  */
+func RenderPdRulePNG(w io.Writer, a *cas.Agent) error {
+    x, d, err := pdRenderableRule(a)
+    if err != nil {
+        return err
+    }
+    n := util.Pow2Int(d)
+    // Cells are scaled up so that small rules are still easy to see:
+    px := 512 / n
+    if px < 1 {
+        px = 1
+    }
+    img := image.NewRGBA(image.Rect(0, 0, n * px, n * px))
+    for i := range x {
+        own, opp := i & (n - 1), i >> uint(d)
+        c := pdCooperateColor
+        if x[i] == DEFECT {
+            c = pdDefectColor
+        }
+        for u := 0; u < px; u++ {
+            for v := 0; v < px; v++ {
+                // Thin lines between the cells, when they are big enough:
+                if px >= 8 && (u == 0 || v == 0) {
+                    img.Set(own * px + u, opp * px + v, pdGridColor)
+                } else {
+                    img.Set(own * px + u, opp * px + v, c)
+                }
+            }
+        }
+    }
+    return png.Encode(w, img)
+}
+
+/* Writes a rule as a Graphviz DOT state-transition graph. Each node is a
+   state, labeled with own history and the opponent's history (oldest
+   first), and colored by the move the rule makes in it. Each state has
+   two edges, one for each reply the opponent might make, labeled with
+   the rule's move and then the reply, which lead to the state after both
+   moves. The state every game starts in, where both players have
+   cooperated, is drawn with a double border. The graph has 4^d nodes, so
+   only rules up to DOT_DEPTH_CAP are drawn.  */
+func RenderPdRuleDOT(w io.Writer, a *cas.Agent) error {
+    x, d, err := pdRenderableRule(a)
+    if err != nil {
+        return err
+    }
+    if d > DOT_DEPTH_CAP {
+        return fmt.Errorf("a depth %d rule would make a graph of %d nodes, so only rules up to depth %d are drawn", d, len(x), DOT_DEPTH_CAP)
+    }
+    n := util.Pow2Int(d)
+    b := bufio.NewWriter(w)
+    fmt.Fprintf(b, "digraph rule {\n")
+    fmt.Fprintf(b, "    label=\"%s\";\n", a.Name())
+    fmt.Fprintf(b, "    node [shape=box, style=filled, fontname=monospace, fontcolor=white];\n")
+    fmt.Fprintf(b, "    edge [fontname=monospace];\n")
+    for i := range x {
+        own, opp := i & (n - 1), i >> uint(d)
+        c := pdCooperateColor
+        if x[i] == DEFECT {
+            c = pdDefectColor
+        }
+        extra := ""
+        if i == 0 {
+            extra = ", peripheries=2"
+        }
+        fmt.Fprintf(b, "    s%d [label=\"%s\\n%s\", fillcolor=\"#%02x%02x%02x\"%s];\n", i,
+                    pdHistoryString(own, d), pdHistoryString(opp, d), c.R, c.G, c.B, extra)
+    }
+    for i := range x {
+        own, opp := i & (n - 1), i >> uint(d)
+        m := x[i]
+        for _, o := range []int{COOPERATE, DEFECT} {
+            // The oldest move of each history drops off, and the new one goes on the end:
+            own2, opp2 := own >> 1 | m << uint(d - 1), opp >> 1 | o << uint(d - 1)
+            fmt.Fprintf(b, "    s%d -> s%d [label=\"%s/%s\"];\n", i, opp2 << uint(d) | own2, pdMoveString(m), pdMoveString(o))
+        }
+    }
+    fmt.Fprintf(b, "}\n")
+    return b.Flush()
+}
+
+// Renders a rule to a PNG file and a DOT file (either path may be empty):
+func SavePdRuleImages(a *cas.Agent, pngPath string, dotPath string) error {
+    save := func(path string, render func(io.Writer, *cas.Agent) error) error {
+        if path == "" {
+            return nil
+        }
+        f, err := os.Create(path)
+        if err != nil {
+            return err
+        }
+        err = render(f, a)
+        if cerr := f.Close(); err == nil {
+            err = cerr
+        }
+        return err
+    }
+    if err := save(pngPath, RenderPdRulePNG); err != nil {
+        return err
+    }
+    return save(dotPath, RenderPdRuleDOT)
+}
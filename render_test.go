@@ -0,0 +1,85 @@
+package main
+
+import (
+    "bytes"
+    "image/png"
+    "strings"
+    "testing"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestRenderPdRulePNG(t *testing.T) {
+    r := util.MakeRand(19)
+    for _, d := range []int{1, 3, 8} {
+        a := cas.MakeAgent(d, r)
+        var b bytes.Buffer
+        if err := RenderPdRulePNG(&b, &a); err != nil {
+            t.Fatal(err)
+        }
+        img, err := png.Decode(&b)
+        if err != nil {
+            t.Fatal(err)
+        }
+        n := util.Pow2Int(d)
+        px := 512 / n
+        if s := img.Bounds().Size(); s.X != 512 || s.Y != 512 {
+            t.Fatalf("a depth %d rule is drawn %dx%d, want 512x512", d, s.X, s.Y)
+        }
+        // The last pixel of each cell is never part of a grid line:
+        x := a.Rule()
+        for i := range x {
+            own, opp := i & (n - 1), i >> uint(d)
+            want := pdCooperateColor
+            if x[i] == DEFECT {
+                want = pdDefectColor
+            }
+            cr, cg, cb, _ := img.At(own * px + px - 1, opp * px + px - 1).RGBA()
+            if uint8(cr >> 8) != want.R || uint8(cg >> 8) != want.G || uint8(cb >> 8) != want.B {
+                t.Fatalf("depth %d: the cell of state %d is the wrong color for move %d", d, i, x[i])
+            }
+        }
+    }
+}
+
+func TestRenderPdRuleDOT(t *testing.T) {
+    a := pdTestClassic(t, "tft", PERSPECTIVE)
+    var b bytes.Buffer
+    if err := RenderPdRuleDOT(&b, a); err != nil {
+        t.Fatal(err)
+    }
+    s := b.String()
+    nodes, edges := 0, 0
+    for _, line := range strings.Split(s, "\n") {
+        switch {
+        case strings.Contains(line, "->"):
+            edges++
+        case strings.HasPrefix(strings.TrimSpace(line), "s") && strings.Contains(line, "[label="):
+            nodes++
+        }
+    }
+    // A depth 1 rule has 4 states, each with an edge for either reply:
+    if nodes != 4 || edges != 8 || strings.Count(s, "peripheries=2") != 1 {
+        t.Fatalf("a depth 1 rule is drawn with %d nodes and %d edges:\n%s", nodes, edges, s)
+    }
+    // tft copies its opponent, so cooperating from the start leads back to the start, and it defects in reply to a defection:
+    for _, want := range []string{"s0 -> s0 [label=\"C/C\"]", "s0 -> s2 [label=\"C/D\"]", "s2 [label=\"C\\nD\""} {
+        if !strings.Contains(s, want) {
+            t.Errorf("the graph of tft has no %s:\n%s", want, s)
+        }
+    }
+
+    // Rules which are too big for a graph, and native strategies, aren't drawn:
+    c := cas.MakeAgent(DOT_DEPTH_CAP + 1, util.MakeRand(1))
+    if err := RenderPdRuleDOT(&bytes.Buffer{}, &c); err == nil {
+        t.Errorf("a depth %d rule was drawn", DOT_DEPTH_CAP + 1)
+    }
+    n := pdTestClassic(t, "grim", PERSPECTIVE)
+    if err := RenderPdRuleDOT(&bytes.Buffer{}, n); err == nil {
+        t.Errorf("native strategy %s was drawn as a graph", n.Name())
+    }
+    if err := RenderPdRulePNG(&bytes.Buffer{}, n); err == nil {
+        t.Errorf("native strategy %s was drawn as a heatmap", n.Name())
+    }
+}
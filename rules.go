@@ -0,0 +1,53 @@
+package main
+
+import (
+    "os"
+    "strings"
+
+    "github.com/prisoners_dilemma/cas"
+)
+
+/* Loads an Agent from a rule in the portable string format (see
+   cas.DecodeClassifier()), given either the string itself or the path
+   of a file holding it, or the name of a classic strategy, to be played
+   with the encoding enc (see MakeClassicAgent()).  */
+func LoadPdRule(s string, enc int) (cas.Agent, error) {
+    if _, err := cas.ClassicStrategy(s); err == nil {
+        return MakeClassicAgent(s, enc)
+    }
+    if !strings.HasPrefix(s, "pdr1:") {
+        b, err := os.ReadFile(s)
+        if err != nil {
+            return cas.Agent{}, err
+        }
+        s = string(b)
+    }
+    return cas.DecodeAgent(s)
+}
+
+/* Makes an Agent which plays a classic strategy. Under the PERSPECTIVE
+   encoding the ones which only look back a few moves are played as the
+   least deep Classifier rule which holds them (see
+   cas.ClassicClassifier()), so their games are worked out exactly, and
+   they can be drawn and exported like any other rule. The rest, and all
+   of them under the JOINT encoding (where a rule in the second seat reads
+   its opponent's moves first), play their native Strategy.  */
+func MakeClassicAgent(name string, enc int) (cas.Agent, error) {
+    st, err := cas.ClassicStrategy(name)
+    if err != nil {
+        return cas.Agent{}, err
+    }
+    if d := cas.ClassicRuleDepth(name); d > 0 && enc == PERSPECTIVE {
+        c, err := cas.ClassicClassifier(name, d)
+        if err != nil {
+            return cas.Agent{}, err
+        }
+        return cas.MakeAgentFromClassifier(c), nil
+    }
+    return cas.MakeStrategyAgent(st), nil
+}
+
+// Writes an Agent's rule to a file in the portable string format:
+func SavePdRule(path string, a *cas.Agent) error {
+    return os.WriteFile(path, []byte(a.Encode() + "\n"), 0644)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/prisoners_dilemma/cas"
+)
+
+/* Makes a cas.Selector from a spec of the form "<scheme>[:<argument>]":
+       - "threshold", Holland's scheme with the resource threshold n,
+       - "tournament[:<k>]", tournament selection among k members (3),
+       - "roulette", fitness-proportionate selection,
+       - "rank", linear rank selection,
+       - "truncation[:<fraction>]", breeding from the best fraction (0.5).  */
+func MakeSelector(spec string, n int) (cas.Selector, error) {
+    f := strings.SplitN(spec, ":", 2)
+    arg := ""
+    if len(f) == 2 {
+        arg = f[1]
+    }
+    switch f[0] {
+    case "", "threshold":
+        return cas.ThresholdSelector{Threshold: n}, nil
+    case "tournament":
+        k := SELECTION_TOURNAMENT_SIZE
+        if arg != "" {
+            var err error
+            k, err = strconv.Atoi(arg)
+            if err != nil || k < 1 {
+                return nil, fmt.Errorf("tournament selection size %q must be a whole number of at least 1", arg)
+            }
+        }
+        return cas.TournamentSelector{Size: k}, nil
+    case "roulette":
+        return cas.RouletteSelector{}, nil
+    case "rank":
+        return cas.RankSelector{}, nil
+    case "truncation":
+        x := SELECTION_TRUNCATION
+        if arg != "" {
+            var err error
+            x, err = strconv.ParseFloat(arg, 64)
+            if err != nil || x <= 0 || x > 1 {
+                return nil, fmt.Errorf("truncation fraction %q must be above 0 and at most 1", arg)
+            }
+        }
+        return cas.TruncationSelector{Fraction: x}, nil
+    }
+    return nil, fmt.Errorf("unknown selection scheme %q (expected threshold, tournament, roulette, rank or truncation)", f[0])
+}
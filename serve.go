@@ -0,0 +1,344 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "net"
+    "net/http"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/prisoners_dilemma/cas"
+)
+
+/* A run of DiscoverPdRule() started through the HTTP API. It is the
+   StatsWriter of its own run, which is how it keeps up with the run's
+   progress.  */
+type pdServerRun struct {
+    id int
+    generationCap int
+    done chan struct{}
+    cancelOnce sync.Once
+
+    mu sync.Mutex
+    state string
+    stats []GenerationStats
+    result *DiscoverPdRuleMetadata
+    err string
+    // Closed and replaced whenever something changes, to wake up streams:
+    changed chan struct{}
+}
+
+// The status of a run, as reported by the API:
+type PdRunStatus struct {
+    Id int `json:"id"`
+    // "running", "done", "canceled" or "failed":
+    State string `json:"state"`
+    // Generations played so far, out of the generation cap:
+    Generation int `json:"generation"`
+    GenerationCap int `json:"generation_cap"`
+    Latest *GenerationStats `json:"latest,omitempty"`
+    Error string `json:"error,omitempty"`
+}
+
+func (run *pdServerRun) Write(st GenerationStats) error {
+    run.mu.Lock()
+    defer run.mu.Unlock()
+    run.stats = append(run.stats, st)
+    run.notify()
+    return nil
+}
+
+// Wakes up anything waiting on the run. The caller must hold run.mu:
+func (run *pdServerRun) notify() {
+    close(run.changed)
+    run.changed = make(chan struct{})
+}
+
+func (run *pdServerRun) finish(md DiscoverPdRuleMetadata, err error) {
+    run.mu.Lock()
+    defer run.mu.Unlock()
+    switch {
+    case err == ErrPdRunCanceled:
+        run.state = "canceled"
+    case err != nil:
+        run.state, run.err = "failed", err.Error()
+    default:
+        run.state, run.result = "done", &md
+    }
+    run.notify()
+}
+
+func (run *pdServerRun) cancel() {
+    run.cancelOnce.Do(func() {
+        close(run.done)
+    })
+}
+
+// The caller must hold run.mu:
+func (run *pdServerRun) status() PdRunStatus {
+    x := PdRunStatus{Id: run.id, State: run.state, Generation: len(run.stats), GenerationCap: run.generationCap, Error: run.err}
+    if n := len(run.stats); n > 0 {
+        st := run.stats[n - 1]
+        x.Latest = &st
+    }
+    return x
+}
+
+/* PdServer is an http.Handler for a JSON-over-HTTP API which runs
+   DiscoverPdRule() in the background:
+       - POST /runs starts a run. The body is a JSON DiscoverPdRuleParams,
+         whose fields are laid over the server's defaults, and the reply
+         is the run's status, with its id.
+       - GET /runs lists the status of every run.
+       - GET /runs/<id> returns the status of a run, with the stats of
+         its latest generation.
+       - GET /runs/<id>/events streams the run's progress as Server-Sent
+         Events: a "generation" event with the GenerationStats of every
+         generation so far and to come, and then a "done" event with its
+         final status.
+       - POST /runs/<id>/cancel stops a run at the end of its generation.
+       - GET /runs/<id>/result returns the DiscoverPdRuleMetadata of a
+         finished run, which includes the champion's rule.
+   Every run shares the server's process, so it only plays SERVE_RUN_CAP
+   runs at once, and turns down runs bigger than pdServeLimits() allows.  */
+type PdServer struct {
+    defaults DiscoverPdRuleParams
+    mu sync.Mutex
+    runs map[int]*pdServerRun
+    nextId int
+    // Runs which haven't finished yet:
+    running int
+}
+
+func MakePdServer(defaults DiscoverPdRuleParams) *PdServer {
+    return &PdServer{defaults: defaults, runs: map[int]*pdServerRun{}, nextId: 1}
+}
+
+/* Serves the API on addr until it fails. The API is unauthenticated and
+   starts runs which can take a lot of CPU time, so addr must be on a
+   loopback interface, and an address without a host (such as :8080) is
+   served on 127.0.0.1.  */
+func ServePd(addr string, defaults DiscoverPdRuleParams) error {
+    host, port, err := net.SplitHostPort(addr)
+    if err != nil {
+        return err
+    }
+    switch ip := net.ParseIP(host); {
+    case host == "":
+        addr = net.JoinHostPort("127.0.0.1", port)
+    case host == "localhost":
+    case ip == nil || !ip.IsLoopback():
+        return fmt.Errorf("%s is not a loopback address, and the API is only served locally", host)
+    }
+    fmt.Printf("Serving the API on http://%s/runs\n", addr)
+    return http.ListenAndServe(addr, MakePdServer(defaults))
+}
+
+func pdReply(w http.ResponseWriter, code int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(code)
+    json.NewEncoder(w).Encode(v)
+}
+
+func pdReplyError(w http.ResponseWriter, code int, err error) {
+    pdReply(w, code, map[string]string{"error": err.Error()})
+}
+
+func (s *PdServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+    path := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+    if path[0] != "runs" || len(path) > 3 {
+        pdReplyError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", req.URL.Path))
+        return
+    }
+    if len(path) == 1 {
+        switch req.Method {
+        case http.MethodGet:
+            s.list(w)
+        case http.MethodPost:
+            s.start(w, req)
+        default:
+            pdReplyError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s /runs is not allowed", req.Method))
+        }
+        return
+    }
+    id, err := strconv.Atoi(path[1])
+    s.mu.Lock()
+    run := s.runs[id]
+    s.mu.Unlock()
+    if err != nil || run == nil {
+        pdReplyError(w, http.StatusNotFound, fmt.Errorf("no such run %s", path[1]))
+        return
+    }
+    action := ""
+    if len(path) == 3 {
+        action = path[2]
+    }
+    method := http.MethodGet
+    if action == "cancel" {
+        method = http.MethodPost
+    }
+    if req.Method != method {
+        pdReplyError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s %s is not allowed", req.Method, req.URL.Path))
+        return
+    }
+    switch action {
+    case "":
+        run.mu.Lock()
+        x := run.status()
+        run.mu.Unlock()
+        pdReply(w, http.StatusOK, x)
+    case "events":
+        s.events(w, req, run)
+    case "cancel":
+        run.cancel()
+        run.mu.Lock()
+        x := run.status()
+        run.mu.Unlock()
+        pdReply(w, http.StatusAccepted, x)
+    case "result":
+        run.mu.Lock()
+        md, x := run.result, run.status()
+        run.mu.Unlock()
+        if md == nil {
+            pdReplyError(w, http.StatusConflict, fmt.Errorf("run %d is %s, so it has no result", id, x.State))
+            return
+        }
+        pdReply(w, http.StatusOK, md)
+    default:
+        pdReplyError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", req.URL.Path))
+    }
+}
+
+func (s *PdServer) list(w http.ResponseWriter) {
+    s.mu.Lock()
+    runs := make([]*pdServerRun, 0, len(s.runs))
+    for _, run := range s.runs {
+        runs = append(runs, run)
+    }
+    s.mu.Unlock()
+    sort.Slice(runs, func(i, j int) bool {
+        return runs[i].id < runs[j].id
+    })
+    xs := make([]PdRunStatus, len(runs))
+    for i, run := range runs {
+        run.mu.Lock()
+        xs[i] = run.status()
+        run.mu.Unlock()
+    }
+    pdReply(w, http.StatusOK, xs)
+}
+
+func (s *PdServer) start(w http.ResponseWriter, req *http.Request) {
+    p := s.defaults
+    d := json.NewDecoder(req.Body)
+    // A misspelled parameter would otherwise start a run with the default:
+    d.DisallowUnknownFields()
+    if err := d.Decode(&p); err != nil {
+        pdReplyError(w, http.StatusBadRequest, fmt.Errorf("bad parameters: %v", err))
+        return
+    }
+    if err := p.Validate(); err != nil {
+        pdReplyError(w, http.StatusBadRequest, err)
+        return
+    }
+    if err := pdServeLimits(&p); err != nil {
+        pdReplyError(w, http.StatusBadRequest, err)
+        return
+    }
+    if p.Seed == USE_SYSTEM_TIME {
+        p.Seed = time.Now().UnixNano()
+    }
+    s.mu.Lock()
+    if s.running >= SERVE_RUN_CAP {
+        s.mu.Unlock()
+        pdReplyError(w, http.StatusTooManyRequests, fmt.Errorf("%d runs are already running, which is as many as are played at once", SERVE_RUN_CAP))
+        return
+    }
+    run := &pdServerRun{id: s.nextId, generationCap: p.GenerationCap, state: "running",
+                        done: make(chan struct{}), changed: make(chan struct{})}
+    s.runs[run.id] = run
+    s.nextId++
+    s.running++
+    s.mu.Unlock()
+
+    p.Squelch, p.Stats, p.Done = true, run, run.done
+    go func() {
+        md, err := pdServeRun(p)
+        // The run no longer counts against the cap by the time anyone can see it has finished:
+        s.mu.Lock()
+        s.running--
+        s.mu.Unlock()
+        run.finish(md, err)
+    }()
+    run.mu.Lock()
+    x := run.status()
+    run.mu.Unlock()
+    pdReply(w, http.StatusCreated, x)
+}
+
+/* Runs DiscoverPdRule() for the server. A panic in the run's own
+   goroutine fails the run instead of ending the server, but a panic in
+   one of the goroutines playing its games, or a fatal error such as
+   running out of memory, still takes down the whole process.  */
+func pdServeRun(p DiscoverPdRuleParams) (md DiscoverPdRuleMetadata, err error) {
+    defer func() {
+        if x := recover(); x != nil {
+            md, err = DiscoverPdRuleMetadata{}, fmt.Errorf("the run panicked: %v", x)
+        }
+    }()
+    return DiscoverPdRule(p)
+}
+
+/* Returns an error if a run is too big for the server: if its Cohort has
+   more than SERVE_COHORT_CAP members, or if it could play more than
+   SERVE_ROUND_CAP rounds in all.  */
+func pdServeLimits(p *DiscoverPdRuleParams) error {
+    if p.CohortSize > SERVE_COHORT_CAP {
+        return fmt.Errorf("cohort size %d is more than the %d the server takes on", p.CohortSize, SERVE_COHORT_CAP)
+    }
+    n := float64(p.NumRounds)
+    rounds := float64(p.CohortSize) * float64(p.GamesPerGen) * float64(p.GenerationCap) * n
+    rounds += float64(p.ControlSampleSize) * n + float64(p.ClassicGames) * float64(len(cas.ClassicStrategies())) * n
+    if rounds > SERVE_ROUND_CAP {
+        return fmt.Errorf("the run could play %g rounds, which is more than the %g the server takes on", rounds, float64(SERVE_ROUND_CAP))
+    }
+    return nil
+}
+
+func (s *PdServer) events(w http.ResponseWriter, req *http.Request, run *pdServerRun) {
+    f, ok := w.(http.Flusher)
+    if !ok {
+        pdReplyError(w, http.StatusInternalServerError, fmt.Errorf("streaming isn't supported"))
+        return
+    }
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    send := func(event string, v interface{}) {
+        b, _ := json.Marshal(v)
+        fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+    }
+    for i := 0; ; {
+        run.mu.Lock()
+        sts, x, ch := run.stats[i:], run.status(), run.changed
+        run.mu.Unlock()
+        for _, st := range sts {
+            send("generation", st)
+            i++
+        }
+        if x.State != "running" {
+            send("done", x)
+            f.Flush()
+            return
+        }
+        f.Flush()
+        select {
+        case <-ch:
+        case <-req.Context().Done():
+            return
+        }
+    }
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+// Starts a server whose runs are small unless a request says otherwise:
+func pdTestServer(t *testing.T) *httptest.Server {
+    p := pdTestParams(t, "-cohortSize=10", "-genCap=3", "-gamesPerGen=5", "-controlSampleSize=100", "-classicGames=1")
+    ts := httptest.NewServer(MakePdServer(p))
+    t.Cleanup(ts.Close)
+    return ts
+}
+
+// Sends a request and decodes the JSON reply into v (if not nil), returning the status code:
+func pdTestRequest(t *testing.T, ts *httptest.Server, method string, path string, body string, v interface{}) int {
+    req, err := http.NewRequest(method, ts.URL + path, strings.NewReader(body))
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    if v != nil {
+        if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+            t.Fatalf("%s %s gave a bad reply: %v", method, path, err)
+        }
+    }
+    return resp.StatusCode
+}
+
+// Starts a run, which must be accepted, and returns its id:
+func pdTestStart(t *testing.T, ts *httptest.Server, body string) int {
+    var x PdRunStatus
+    if code := pdTestRequest(t, ts, http.MethodPost, "/runs", body, &x); code != http.StatusCreated {
+        t.Fatalf("POST /runs %s gave %d", body, code)
+    }
+    return x.Id
+}
+
+/* Follows the events of a run until it is over, and returns its
+   generation events and its final status.  */
+func pdTestEvents(t *testing.T, ts *httptest.Server, id int) ([]GenerationStats, PdRunStatus) {
+    resp, err := http.Get(fmt.Sprintf("%s/runs/%d/events", ts.URL, id))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+        t.Fatalf("events of run %d have content type %q", id, ct)
+    }
+    var sts []GenerationStats
+    event := ""
+    sc := bufio.NewScanner(resp.Body)
+    for sc.Scan() {
+        line := sc.Text()
+        switch {
+        case strings.HasPrefix(line, "event: "):
+            event = line[len("event: "):]
+        case strings.HasPrefix(line, "data: "):
+            data := []byte(line[len("data: "):])
+            if event == "done" {
+                var x PdRunStatus
+                if err := json.Unmarshal(data, &x); err != nil {
+                    t.Fatal(err)
+                }
+                return sts, x
+            }
+            var st GenerationStats
+            if err := json.Unmarshal(data, &st); err != nil {
+                t.Fatal(err)
+            }
+            sts = append(sts, st)
+        }
+    }
+    t.Fatalf("the events of run %d ended without a done event", id)
+    return nil, PdRunStatus{}
+}
+
+func TestPdServerRun(t *testing.T) {
+    ts := pdTestServer(t)
+    ids := []int{pdTestStart(t, ts, `{"Seed": 3, "GenerationCap": 2}`), pdTestStart(t, ts, `{"Seed": 3, "GenerationCap": 2}`), pdTestStart(t, ts, `{}`)}
+    var mds []DiscoverPdRuleMetadata
+    for _, id := range ids {
+        sts, x := pdTestEvents(t, ts, id)
+        if x.State != "done" || x.Id != id || x.Generation != len(sts) || len(sts) == 0 || len(sts) > x.GenerationCap {
+            t.Fatalf("run %d ended as %+v after %d generation events", id, x, len(sts))
+        }
+        for i, st := range sts {
+            if st.Generation != i {
+                t.Fatalf("run %d sent generation %d as event %d", id, st.Generation, i)
+            }
+        }
+        var y PdRunStatus
+        if code := pdTestRequest(t, ts, http.MethodGet, fmt.Sprintf("/runs/%d", id), "", &y); code != http.StatusOK || y.State != "done" || *y.Latest != sts[len(sts) - 1] {
+            t.Fatalf("GET /runs/%d gave %d: %+v", id, code, y)
+        }
+        var md DiscoverPdRuleMetadata
+        if code := pdTestRequest(t, ts, http.MethodGet, fmt.Sprintf("/runs/%d/result", id), "", &md); code != http.StatusOK || md.EncodedRule == "" {
+            t.Fatalf("GET /runs/%d/result gave %d: %+v", id, code, md)
+        }
+        mds = append(mds, md)
+    }
+    var xs []PdRunStatus
+    if code := pdTestRequest(t, ts, http.MethodGet, "/runs", "", &xs); code != http.StatusOK || len(xs) != 3 {
+        t.Fatalf("GET /runs gave %d: %+v", code, xs)
+    }
+    for i, x := range xs {
+        if want := []int{2, 2, 3}[i]; x.Id != ids[i] || x.GenerationCap != want {
+            t.Fatalf("run %d has generation cap %d, want %d", x.Id, x.GenerationCap, want)
+        }
+    }
+    // The same seed gives the same run, and a run without one gets its own:
+    if mds[0].Seed != 3 || mds[0].EncodedRule != mds[1].EncodedRule || mds[0].RuleWinPercent != mds[1].RuleWinPercent {
+        t.Fatalf("two runs with seed 3 found %s (%g) and %s (%g)", mds[0].EncodedRule, mds[0].RuleWinPercent, mds[1].EncodedRule, mds[1].RuleWinPercent)
+    }
+    if mds[2].Seed == USE_SYSTEM_TIME {
+        t.Fatalf("a run without a seed was reported with seed %d", mds[2].Seed)
+    }
+}
+
+func TestPdServerCancel(t *testing.T) {
+    ts := pdTestServer(t)
+    id := pdTestStart(t, ts, `{"GenerationCap": 1000000, "FitnessGoal": 100}`)
+    var x PdRunStatus
+    if code := pdTestRequest(t, ts, http.MethodPost, fmt.Sprintf("/runs/%d/cancel", id), "", &x); code != http.StatusAccepted {
+        t.Fatalf("canceling run %d gave %d", id, code)
+    }
+    if _, x = pdTestEvents(t, ts, id); x.State != "canceled" {
+        t.Fatalf("run %d ended as %s after being canceled", id, x.State)
+    }
+    if code := pdTestRequest(t, ts, http.MethodGet, fmt.Sprintf("/runs/%d/result", id), "", nil); code != http.StatusConflict {
+        t.Fatalf("the result of a canceled run gave %d", code)
+    }
+}
+
+func TestPdServerRunCap(t *testing.T) {
+    ts := pdTestServer(t)
+    var ids []int
+    for i := 0; i < SERVE_RUN_CAP; i++ {
+        ids = append(ids, pdTestStart(t, ts, `{"GenerationCap": 1000000, "FitnessGoal": 100}`))
+    }
+    if code := pdTestRequest(t, ts, http.MethodPost, "/runs", `{}`, nil); code != http.StatusTooManyRequests {
+        t.Fatalf("a run past the cap gave %d", code)
+    }
+    for _, id := range ids {
+        pdTestRequest(t, ts, http.MethodPost, fmt.Sprintf("/runs/%d/cancel", id), "", nil)
+        pdTestEvents(t, ts, id)
+    }
+    // Once a run is seen to have finished, it no longer counts:
+    pdTestStart(t, ts, `{}`)
+}
+
+func TestPdServerErrors(t *testing.T) {
+    ts := pdTestServer(t)
+    id := pdTestStart(t, ts, `{}`)
+    tests := []struct {
+        method string
+        path string
+        body string
+        code int
+    }{
+        {http.MethodPost, "/runs", `{"CohortSise": 20}`, http.StatusBadRequest},
+        {http.MethodPost, "/runs", `{"CohortSize": 1}`, http.StatusBadRequest},
+        {http.MethodPost, "/runs", `{"DecisionDepth": 20}`, http.StatusBadRequest},
+        {http.MethodPost, "/runs", `{"CohortSize": 20000}`, http.StatusBadRequest},
+        {http.MethodPost, "/runs", `{"NumRounds": 2000000000}`, http.StatusBadRequest},
+        {http.MethodPost, "/runs", `{"Seed": "x"}`, http.StatusBadRequest},
+        {http.MethodPost, "/runs", `{`, http.StatusBadRequest},
+        {http.MethodGet, "/nope", "", http.StatusNotFound},
+        {http.MethodGet, "/runs/99", "", http.StatusNotFound},
+        {http.MethodGet, "/runs/x", "", http.StatusNotFound},
+        {http.MethodGet, fmt.Sprintf("/runs/%d/bogus", id), "", http.StatusNotFound},
+        {http.MethodGet, fmt.Sprintf("/runs/%d/result/x", id), "", http.StatusNotFound},
+        {http.MethodPut, "/runs", "", http.StatusMethodNotAllowed},
+        {http.MethodDelete, fmt.Sprintf("/runs/%d", id), "", http.StatusMethodNotAllowed},
+        {http.MethodGet, fmt.Sprintf("/runs/%d/cancel", id), "", http.StatusMethodNotAllowed},
+        {http.MethodPost, fmt.Sprintf("/runs/%d/result", id), "", http.StatusMethodNotAllowed},
+    }
+    for _, test := range tests {
+        var e map[string]string
+        if code := pdTestRequest(t, ts, test.method, test.path, test.body, &e); code != test.code || e["error"] == "" {
+            t.Errorf("%s %s %s gave %d %v, want %d with an error", test.method, test.path, test.body, code, e, test.code)
+        }
+    }
+    pdTestEvents(t, ts, id)
+
+    // Only loopback addresses are served:
+    for _, addr := range []string{"0.0.0.0:0", "8.8.8.8:0", "example.com:0", "nope"} {
+        if err := ServePd(addr, pdTestParams(t)); err == nil {
+            t.Errorf("ServePd(%q) was allowed", addr)
+        }
+    }
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/lock"
+    "github.com/prisoners_dilemma/util"
+)
+
+// The shape and rules of a spatial run (see RunSpatialPd()):
+type PdSpatialParams struct {
+    Width int
+    Height int
+    // "moore" (8 neighbors) or "vonneumann" (4 neighbors):
+    Neighborhood string
+    // "imitate" (copy the best neighbor) or "combine" (cross the best two):
+    Reproduction string
+    // "sync" (every cell at once) or "async" (one random cell at a time):
+    Update string
+}
+
+/* Reads a grid size of the form "<width>x<height>" (or just "<width>",
+   for a square grid) into the parameters.  */
+func (sp *PdSpatialParams) SetGrid(s string) error {
+    f := strings.SplitN(s, "x", 2)
+    if len(f) == 1 {
+        f = append(f, f[0])
+    }
+    w, err := strconv.Atoi(f[0])
+    if err == nil {
+        sp.Height, err = strconv.Atoi(f[1])
+    }
+    if err != nil {
+        return fmt.Errorf("grid size %q must be of the form <width>x<height>", s)
+    }
+    sp.Width = w
+    return nil
+}
+
+func (sp *PdSpatialParams) resolve() (cas.Neighborhood, cas.Reproduction, bool, error) {
+    var nb cas.Neighborhood
+    var rp cas.Reproduction
+    if sp.Width < 3 || sp.Height < 3 {
+        return nb, rp, false, fmt.Errorf("grid %dx%d must be at least 3x3", sp.Width, sp.Height)
+    }
+    switch sp.Neighborhood {
+    case "", "moore":
+        nb = cas.MOORE
+    case "vonneumann":
+        nb = cas.VON_NEUMANN
+    default:
+        return nb, rp, false, fmt.Errorf("unknown neighborhood %q (expected moore or vonneumann)", sp.Neighborhood)
+    }
+    switch sp.Reproduction {
+    case "", "imitate":
+        rp = cas.IMITATE
+    case "combine":
+        rp = cas.COMBINE
+    default:
+        return nb, rp, false, fmt.Errorf("unknown reproduction %q (expected imitate or combine)", sp.Reproduction)
+    }
+    switch sp.Update {
+    case "", "sync":
+        return nb, rp, false, nil
+    case "async":
+        return nb, rp, true, nil
+    }
+    return nb, rp, false, fmt.Errorf("unknown update mode %q (expected sync or async)", sp.Update)
+}
+
+// Returns an error if the parameters can't make a sensible spatial run:
+func (sp *PdSpatialParams) Validate() error {
+    _, _, _, err := sp.resolve()
+    return err
+}
+
+type PdSpatialResult struct {
+    Width int
+    Height int
+    Neighborhood string
+    Reproduction string
+    Update string
+    GenerationsUsed int
+    // Of the last generation played (see pdLatticeStats()):
+    Fitness float64
+    CooperationRate float64
+    Genotypes int
+    // The most common rule at the end, and the percentage of cells holding it:
+    Rule []int
+    EncodedRule string
+    RuleShare float64
+    RuleWinPercent float64
+    RuleSampledPercent float64
+    Classics []PdBenchmark
+    Seed int64
+}
+
+/* Plays Prisoner's Dilemma on a Lattice for GenerationCap generations.
+   Every generation, each cell plays each of its neighbors (see
+   pdLatticeScores()), and is scored by its total payoff. Then each cell
+   which has a neighbor with a better score than its own is replaced by
+   an offspring of that neighborhood (see cas.Lattice.Offspring()). With
+   the "sync" update every cell is decided on the scores of the whole
+   generation and then replaced at once. With "async", one random cell
+   at a time is updated in place, as many times as there are cells, and
+   its neighborhood is rescored each time so that it only ever sees the
+   grid as it stands. The most common rule at the end is tested like a
+   champion in DiscoverPdRule(). The seed, depth, game, crossover,
+   mutation and output parameters are taken from p.  */
+func RunSpatialPd(p DiscoverPdRuleParams, sp PdSpatialParams) (PdSpatialResult, error) {
+    if err := p.Validate(); err != nil {
+        return PdSpatialResult{}, err
+    }
+    nb, rp, async, err := sp.resolve()
+    if err != nil {
+        return PdSpatialResult{}, err
+    }
+    gp := p.Game()
+    x, _ := MakeCrossover(p.Crossover)
+    m, _ := MakeMutator(p.Mutation, p.MutationFrequency, p.GenerationCap)
+
+    l := cas.MakeLattice(sp.Width, sp.Height, p.DecisionDepth, util.MakeRand(util.DeriveSeed(p.Seed, -1)))
+    score := make([]float64, l.Size())
+    all := make([]int, l.Size())
+    for i := range all {
+        all[i] = i
+    }
+
+    if !p.Squelch {
+        fmt.Printf("Playing Prisoner's Dilemma on a %dx%d lattice...\n", sp.Width, sp.Height)
+    }
+    t := time.Now()
+    st := GenerationStats{}
+    for l.Generation() < p.GenerationCap {
+        if !p.Squelch {
+            fmt.Printf("Generation %d / %d\n", l.Generation(), p.GenerationCap - 1)
+        }
+        r := util.MakeRand(util.DeriveSeed(p.Seed, l.Generation()))
+
+        // Score the whole generation:
+        coop := pdLatticeScores(&l, all, nb, gp, score, r)
+        st = pdLatticeStats(&l, nb, gp, score, coop)
+        m.Observe(l.Generation(), st.Fitness)
+
+        // Replace the cells which were outdone:
+        born := []cas.Agent{}
+        if async {
+            for k := 0; k < l.Size(); k++ {
+                i := r.Intn(l.Size())
+                pdLatticeScores(&l, append([]int{i}, l.Neighbors(i, nb)...), nb, gp, score, r)
+                if a, ok := l.Offspring(i, nb, rp, score, x, m, r); ok {
+                    l.Place(i, a)
+                    born = append(born, a)
+                }
+            }
+        } else {
+            next := make([]*cas.Agent, l.Size())
+            for i := range next {
+                if a, ok := l.Offspring(i, nb, rp, score, x, m, r); ok {
+                    next[i] = &a
+                }
+            }
+            for i := range next {
+                if next[i] != nil {
+                    l.Place(i, *next[i])
+                    born = append(born, *next[i])
+                }
+            }
+        }
+        st.Reproducers = len(born)
+        for i := range born {
+            st.MutationRate += born[i].MutationRate() / float64(len(born))
+        }
+        l.Advance()
+
+        if p.Stats != nil {
+            st.Seconds = time.Since(t).Seconds()
+            if err := p.Stats.Write(st); err != nil {
+                return PdSpatialResult{}, err
+            }
+        }
+        if !p.Squelch {
+            fmt.Printf("\tLattice Fitness: %.02f (%.02f percent cooperation)\n", st.Fitness, st.CooperationRate)
+        }
+    }
+
+    // Test the most common rule:
+    r := util.MakeRand(util.DeriveSeed(p.Seed, l.Generation()))
+    v, n := l.Dominant()
+    res := PdSpatialResult{
+        Width: sp.Width,
+        Height: sp.Height,
+        Neighborhood: sp.Neighborhood,
+        Reproduction: sp.Reproduction,
+        Update: sp.Update,
+        GenerationsUsed: l.Generation(),
+        Fitness: st.Fitness,
+        CooperationRate: st.CooperationRate,
+        Genotypes: l.Genotypes(),
+        Rule: v.Rule(),
+        EncodedRule: v.Encode(),
+        RuleShare: util.Percent(float64(n), float64(l.Size())),
+        Seed: p.Seed,
+    }
+    if !p.Squelch {
+        fmt.Printf("Testing the most common rule against random opponents...\n")
+    }
+    res.RuleWinPercent, res.RuleSampledPercent = pdRuleWinPercent(v, gp, p.ControlSampleSize, true, r)
+    if p.ClassicGames > 0 {
+        res.Classics = pdTestAgentAgainstClassics(v, gp, p.ClassicGames, r)
+    }
+    return res, nil
+}
+
+/* Has each of the given cells play each of its neighbors twice, once
+   from each seat (the state a Classifier sees depends on which player
+   it is, so this keeps the scores fair), and sets its score to its
+   total payoff as a gain (see PdPayoff.Gain()) and its resources to the
+   number of those games it won. Each cell is a job of its own, which
+   only writes to its own cell. Returns the number of moves played by
+   the cells which were to cooperate.  */
+func pdLatticeScores(l *cas.Lattice,
+                     cells []int,
+                     nb cas.Neighborhood,
+                     gp PdGameParams,
+                     score []float64,
+                     r *rand.Rand) int {
+    h := make([]int, len(cells))
+    pl := lock.MakePool(GOROUTINE_CAP)
+    for k := range cells {
+        j, s := k, r.Int63()
+        pl.Go(func() {
+            g := util.MakeRand(s)
+            i := cells[j]
+            a := l.Cell(i)
+            t, w := 0, 0
+            for _, n := range l.Neighbors(i, nb) {
+                x := pdGame(a, l.Cell(n), gp, false, g)
+                y := pdGame(l.Cell(n), a, gp, false, g)
+                t += x.ScoreA + y.ScoreB
+                if x.Winner == a && !x.Tie {
+                    w++
+                }
+                if y.Winner == a && !y.Tie {
+                    w++
+                }
+                h[j] += x.CooperationsA + y.CooperationsB
+            }
+            score[i] = float64(gp.Payoff.Gain(t))
+            a.TakeResources(a.Resources())
+            a.AddResources(w)
+        })
+    }
+    pl.Join()
+    n := 0
+    for i := range h {
+        n += h[i]
+    }
+    return n
+}
+
+/* Collects the stats for a Lattice whose every cell has just been
+   scored. The fitness is the mean payoff per round, as a percentage of
+   the way from mutual punishment (0) to mutual reward (100), so it reads
+   the same under either scoring convention.  */
+func pdLatticeStats(l *cas.Lattice,
+                    nb cas.Neighborhood,
+                    gp PdGameParams,
+                    score []float64,
+                    coop int) GenerationStats {
+    st := GenerationStats{Generation: l.Generation()}
+    rounds := float64(l.Size() * len(l.Neighbors(0, nb)) * 2 * gp.NumRounds)
+    t := 0.0
+    st.MinResources = l.Cell(0).Resources()
+    for i := 0; i < l.Size(); i++ {
+        t += score[i]
+        n := l.Cell(i).Resources()
+        if n < st.MinResources {
+            st.MinResources = n
+        }
+        if n > st.MaxResources {
+            st.MaxResources = n
+        }
+        st.MeanResources += float64(n) / float64(l.Size())
+    }
+    mean := float64(gp.Payoff.Gain(1)) * t / rounds
+    st.Fitness = util.Percent(mean - float64(gp.Payoff.Punishment), float64(gp.Payoff.Reward - gp.Payoff.Punishment))
+    st.Genotypes = l.Genotypes()
+    st.CooperationRate = util.Percent(float64(coop), rounds)
+    return st
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+
+    "github.com/prisoners_dilemma/cas"
+)
+
+// A record of one generation of DiscoverPdRule():
+type GenerationStats struct {
+    Generation int `json:"generation"`
+    Fitness float64 `json:"fitness"`
+    MinResources int `json:"min_resources"`
+    MeanResources float64 `json:"mean_resources"`
+    MaxResources int `json:"max_resources"`
+    // Members with enough resources to reproduce in Cohort.Evolve():
+    Reproducers int `json:"reproducers"`
+    // Distinct Classifier rules in the Cohort:
+    Genotypes int `json:"genotypes"`
+    // Percentage of the Cohort's moves which were to cooperate:
+    CooperationRate float64 `json:"cooperation_rate"`
+    // Mean per-bit mutation rate of the offspring bred this generation:
+    MutationRate float64 `json:"mutation_rate"`
+    // The island the record is for, counting from 1 (0 outside of RunIslands()):
+    Island int `json:"island,omitempty"`
+    // Wall-clock time since the start of the run:
+    Seconds float64 `json:"seconds"`
+}
+
+/* Collects the stats for a Cohort which has just played a generation
+   (but which hasn't evolved yet).  */
+func pdGenerationStats(c *cas.Cohort, coop float64) GenerationStats {
+    st := GenerationStats{}
+    st.Generation = c.Generation()
+    st.Fitness = c.Fitness()
+    st.MinResources = c.Member(0).Resources()
+    t := 0
+    for i := 0; i < c.Size(); i++ {
+        n := c.Member(i).Resources()
+        if n < st.MinResources {
+            st.MinResources = n
+        }
+        if n > st.MaxResources {
+            st.MaxResources = n
+        }
+        t += n
+    }
+    st.MeanResources = float64(t) / float64(c.Size())
+    st.Genotypes = c.Genotypes()
+    st.CooperationRate = coop
+    return st
+}
+
+/* Returns the mean mutation rate of the members of a Cohort which has
+   just evolved that were born in the evolution (or 0 if none were).  */
+func pdMutationRate(c *cas.Cohort) float64 {
+    t, n := 0.0, 0
+    for i := 0; i < c.Size(); i++ {
+        if a := c.Member(i); a.Metadata.Generation == c.Generation() {
+            t += a.MutationRate()
+            n++
+        }
+    }
+    if n == 0 {
+        return 0
+    }
+    return t / float64(n)
+}
+
+// Writes GenerationStats records out in some format:
+type StatsWriter interface {
+    Write(st GenerationStats) error
+}
+
+/* Returns a StatsWriter for the given format, which is either "csv" or
+   "jsonl" (JSON Lines, one object per generation).  */
+func MakeStatsWriter(w io.Writer, format string) (StatsWriter, error) {
+    switch format {
+    case "csv":
+        return &csvStatsWriter{w: csv.NewWriter(w)}, nil
+    case "jsonl":
+        return &jsonlStatsWriter{json.NewEncoder(w)}, nil
+    }
+    return nil, fmt.Errorf("unknown stats format %q (expected csv or jsonl)", format)
+}
+
+/* Returns a StatsWriter which carries on from records already written by
+   another, as when a run is resumed, so a CSV header isn't written again.  */
+func MakeStatsAppender(w io.Writer, format string) (StatsWriter, error) {
+    sw, err := MakeStatsWriter(w, format)
+    if x, ok := sw.(*csvStatsWriter); ok {
+        x.header = true
+    }
+    return sw, err
+}
+
+type csvStatsWriter struct {
+    w *csv.Writer
+    header bool
+}
+
+func (sw *csvStatsWriter) Write(st GenerationStats) error {
+    if !sw.header {
+        sw.header = true
+        err := sw.w.Write([]string{"generation", "fitness", "min_resources", "mean_resources",
+                                   "max_resources", "reproducers", "genotypes", "cooperation_rate", "mutation_rate", "seconds", "island"})
+        if err != nil {
+            return err
+        }
+    }
+    f := func(x float64) string {
+        return strconv.FormatFloat(x, 'f', -1, 64)
+    }
+    err := sw.w.Write([]string{strconv.Itoa(st.Generation), f(st.Fitness), strconv.Itoa(st.MinResources),
+                               f(st.MeanResources), strconv.Itoa(st.MaxResources), strconv.Itoa(st.Reproducers),
+                               strconv.Itoa(st.Genotypes), f(st.CooperationRate), f(st.MutationRate),
+                               f(st.Seconds), strconv.Itoa(st.Island)})
+    if err != nil {
+        return err
+    }
+    // Flushed every generation so that a run can be watched as it goes:
+    sw.w.Flush()
+    return sw.w.Error()
+}
+
+type jsonlStatsWriter struct {
+    e *json.Encoder
+}
+
+func (sw *jsonlStatsWriter) Write(st GenerationStats) error {
+    return sw.e.Encode(st)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/csv"
+    "encoding/json"
+    "reflect"
+    "testing"
+)
+
+func pdTestStats(n int) []GenerationStats {
+    sts := make([]GenerationStats, n)
+    for i := range sts {
+        sts[i] = GenerationStats{Generation: i, Fitness: 12.5 * float64(i), MinResources: i, MeanResources: 1.25,
+                                 MaxResources: 2 * i, Reproducers: 3, Genotypes: 7, CooperationRate: 50,
+                                 MutationRate: 0.001, Island: i % 2, Seconds: 0.5}
+    }
+    return sts
+}
+
+func TestStatsWriterCSV(t *testing.T) {
+    sts := pdTestStats(4)
+    var b bytes.Buffer
+    sw, err := MakeStatsWriter(&b, "csv")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, st := range sts[:2] {
+        if err := sw.Write(st); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // A resumed run carries on in the same file, without a second header:
+    sw, err = MakeStatsAppender(&b, "csv")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, st := range sts[2:] {
+        if err := sw.Write(st); err != nil {
+            t.Fatal(err)
+        }
+    }
+    rows, err := csv.NewReader(&b).ReadAll()
+    if err != nil {
+        t.Fatal(err)
+    }
+    header := []string{"generation", "fitness", "min_resources", "mean_resources", "max_resources", "reproducers", "genotypes", "cooperation_rate", "mutation_rate", "seconds", "island"}
+    if len(rows) != 5 || !reflect.DeepEqual(rows[0], header) {
+        t.Fatalf("the CSV has rows %q, want a header and 4 records", rows)
+    }
+    want := [][]string{
+        {"0", "0", "0", "1.25", "0", "3", "7", "50", "0.001", "0.5", "0"},
+        {"1", "12.5", "1", "1.25", "2", "3", "7", "50", "0.001", "0.5", "1"},
+        {"2", "25", "2", "1.25", "4", "3", "7", "50", "0.001", "0.5", "0"},
+        {"3", "37.5", "3", "1.25", "6", "3", "7", "50", "0.001", "0.5", "1"},
+    }
+    if !reflect.DeepEqual(rows[1:], want) {
+        t.Fatalf("the CSV has records %q, want %q", rows[1:], want)
+    }
+}
+
+func TestStatsWriterJSONL(t *testing.T) {
+    sts := pdTestStats(3)
+    var b bytes.Buffer
+    sw, err := MakeStatsWriter(&b, "jsonl")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := sw.Write(sts[0]); err != nil {
+        t.Fatal(err)
+    }
+    sw, err = MakeStatsAppender(&b, "jsonl")
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, st := range sts[1:] {
+        if err := sw.Write(st); err != nil {
+            t.Fatal(err)
+        }
+    }
+    sc := bufio.NewScanner(&b)
+    i := 0
+    for ; sc.Scan(); i++ {
+        var st GenerationStats
+        if err := json.Unmarshal(sc.Bytes(), &st); err != nil {
+            t.Fatalf("line %d is %q: %v", i, sc.Text(), err)
+        }
+        if st != sts[i] {
+            t.Errorf("line %d reads back as %+v, want %+v", i, st, sts[i])
+        }
+    }
+    if i != len(sts) {
+        t.Fatalf("%d lines were written, want %d", i, len(sts))
+    }
+}
+
+func TestStatsWriterFormat(t *testing.T) {
+    for _, f := range []string{"", "CSV", "json", "tsv"} {
+        if _, err := MakeStatsWriter(&bytes.Buffer{}, f); err == nil {
+            t.Errorf("stats format %q was accepted", f)
+        }
+        if _, err := MakeStatsAppender(&bytes.Buffer{}, f); err == nil {
+            t.Errorf("stats format %q was accepted for appending", f)
+        }
+    }
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/lock"
+    "github.com/prisoners_dilemma/util"
+)
+
+// An entrant's totals over a whole tournament:
+type TournamentStanding struct {
+    Entrant int
+    Name string
+    Games int
+    Wins int
+    Ties int
+    Losses int
+    // Mean payoff per round, over all of the entrant's games:
+    MeanScore float64
+}
+
+type TournamentResult struct {
+    Names []string
+    // Standings, ranked from best to worst:
+    Standings []TournamentStanding
+    /* Scores[i][j] is entrant i's mean payoff per round against entrant j,
+       with the entrants in the order they were given.  */
+    Scores [][]float64
+}
+
+/* Runs an Axelrod-style round-robin tournament: every pair of entrants
+   plays reps games against each other (and each entrant plays itself, if
+   self is set). Games against itself count towards an entrant's score
+   but not its wins, ties or losses. Entrants are ranked by mean payoff
+   per round, then by wins.  */
+func RunTournament(e []*cas.Agent, 
+                   names []string, 
+                   gp PdGameParams, 
+                   reps int, 
+                   self bool, 
+                   r *rand.Rand) TournamentResult {
+    n := len(e)
+    sum := make([][]int, n)
+    games := make([][]int, n)
+    wins := make([][]int, n)
+    ties := make([][]int, n)
+    for i := range e {
+        sum[i], games[i], wins[i], ties[i] = make([]int, n), make([]int, n), make([]int, n), make([]int, n)
+    }
+
+    /* Each job plays out one row of the upper triangle of the matrix, and
+       fills in both (i, j) and (j, i), so no two jobs touch the same cell.  */
+    pl := lock.MakePool(GOROUTINE_CAP)
+    for i := range e {
+        k, s := i, r.Int63()
+        pl.Go(func() {
+            g := util.MakeRand(s)
+            for j := k; j < n; j++ {
+                if j == k && !self {
+                    continue
+                }
+                for h := 0; h < reps; h++ {
+                    x := pdGame(e[k], e[j], gp, false, g)
+                    sum[k][j] += x.ScoreA
+                    games[k][j]++
+                    if j == k {
+                        continue
+                    }
+                    sum[j][k] += x.ScoreB
+                    games[j][k]++
+                    if x.Tie {
+                        ties[k][j]++
+                        ties[j][k]++
+                    } else if x.Winner == e[k] {
+                        wins[k][j]++
+                    } else {
+                        wins[j][k]++
+                    }
+                }
+            }
+        })
+    }
+    pl.Join()
+
+    res := TournamentResult{Names: names, Scores: make([][]float64, n)}
+    for i := range e {
+        res.Scores[i] = make([]float64, n)
+        st := TournamentStanding{Entrant: i, Name: names[i]}
+        t := 0
+        for j := range e {
+            if games[i][j] > 0 {
+                res.Scores[i][j] = float64(sum[i][j]) / float64(games[i][j] * gp.NumRounds)
+            }
+            st.Games += games[i][j]
+            t += sum[i][j]
+            if i != j {
+                st.Wins += wins[i][j]
+                st.Ties += ties[i][j]
+                st.Losses += games[i][j] - wins[i][j] - ties[i][j]
+            }
+        }
+        if st.Games > 0 {
+            st.MeanScore = float64(t) / float64(st.Games * gp.NumRounds)
+        }
+        res.Standings = append(res.Standings, st)
+    }
+    sort.SliceStable(res.Standings, func(i, j int) bool {
+        x, y := res.Standings[i], res.Standings[j]
+        if x.MeanScore != y.MeanScore {
+            return gp.Payoff.BetterMean(x.MeanScore, y.MeanScore)
+        }
+        return x.Wins > y.Wins
+    })
+    return res
+}
+
+/* Makes the entrants for a tournament from a comma-separated list. Each
+   item is one of:
+       - the name of a classic strategy (see cas.ClassicStrategies()),
+       - "classics", for all of the classic strategies,
+       - "random" or "random:<n>", for one or n random rules at depth d,
+       - a rule in the portable string format, or a file holding one.  */
+func MakeTournamentEntrants(list string, gp PdGameParams, r *rand.Rand) ([]*cas.Agent, []string, error) {
+    var e []*cas.Agent
+    var names []string
+    add := func(a cas.Agent, name string) {
+        e = append(e, &a)
+        names = append(names, name)
+    }
+    classics := cas.ClassicStrategies()
+    for k, item := range strings.Split(list, ",") {
+        item = strings.TrimSpace(item)
+        switch {
+        case item == "":
+            continue
+        case item == "classics":
+            for _, c := range classics {
+                a, _ := MakeClassicAgent(c, gp.Encoding)
+                add(a, c)
+            }
+        case item == "random" || strings.HasPrefix(item, "random:"):
+            m := 1
+            if item != "random" {
+                var err error
+                m, err = strconv.Atoi(strings.TrimPrefix(item, "random:"))
+                if err != nil || m < 1 {
+                    return nil, nil, fmt.Errorf("bad number of random entrants in %q", item)
+                }
+            }
+            for i := 0; i < m; i++ {
+                add(cas.MakeAgent(gp.DecisionDepth, r), fmt.Sprintf("random%d", len(e) + 1))
+            }
+        default:
+            if a, err := MakeClassicAgent(item, gp.Encoding); err == nil {
+                add(a, item)
+                continue
+            }
+            a, err := LoadPdRule(item, gp.Encoding)
+            if err != nil {
+                return nil, nil, fmt.Errorf("entrant %d (%q) isn't a classic strategy, random or a rule: %v", k + 1, item, err)
+            }
+            name := fmt.Sprintf("rule%d", len(e) + 1)
+            if !strings.HasPrefix(item, "pdr1:") {
+                name = filepath.Base(item)
+            }
+            add(a, name)
+        }
+    }
+    if len(e) < 2 {
+        return nil, nil, fmt.Errorf("a tournament needs at least 2 entrants, got %d", len(e))
+    }
+    return e, names, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+    "math"
+    "testing"
+
+    "github.com/prisoners_dilemma/cas"
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestRunTournamentClassics(t *testing.T) {
+    names := []string{"allc", "alld", "tft"}
+    for _, scoring := range []int{SCORE_YEARS, SCORE_POINTS} {
+        gp := PdGameParams{NumRounds: 10, DecisionDepth: 1, Payoff: DefaultPdPayoff(scoring)}
+        e := make([]*cas.Agent, len(names))
+        for i, n := range names {
+            a, err := MakeClassicAgent(n, gp.Encoding)
+            if err != nil {
+                t.Fatal(err)
+            }
+            e[i] = &a
+        }
+        res := RunTournament(e, names, gp, 3, true, util.MakeRand(9))
+
+        // Mean payoffs per round: tft is suckered once by alld, and punished for the other 9 rounds:
+        p := gp.Payoff
+        tr, rr, pr, sr := float64(p.Temptation), float64(p.Reward), float64(p.Punishment), float64(p.Suckers)
+        want := [][]float64{
+            {rr, sr, rr},
+            {tr, pr, (tr + 9 * pr) / 10},
+            {rr, (sr + 9 * pr) / 10, rr},
+        }
+        for i := range want {
+            for j := range want[i] {
+                if math.Abs(res.Scores[i][j] - want[i][j]) > 1e-9 {
+                    t.Errorf("scoring %d: %s scores %g against %s, want %g", scoring, names[i], res.Scores[i][j], names[j], want[i][j])
+                }
+            }
+        }
+
+        // alld beats everyone, and the others tie with each other, so it's alld, tft, allc under either scoring:
+        type record struct {
+            name string
+            wins, ties, losses int
+        }
+        order := []record{{"alld", 6, 0, 0}, {"tft", 0, 3, 3}, {"allc", 0, 3, 3}}
+        for k, st := range res.Standings {
+            x := record{st.Name, st.Wins, st.Ties, st.Losses}
+            if x != order[k] || st.Games != 9 || names[st.Entrant] != st.Name {
+                t.Errorf("scoring %d: standing %d is %+v, want %+v in 9 games", scoring, k, st, order[k])
+            }
+        }
+    }
+}
+
+/* Simultaneous games between Classifiers don't depend on the PRNG, so
+   the entrants in the opposite order play the same tournament.  */
+func TestRunTournamentSymmetry(t *testing.T) {
+    r := util.MakeRand(10)
+    gp := PdGameParams{NumRounds: 50, DecisionDepth: 2, Payoff: DefaultPdPayoff(SCORE_POINTS)}
+    n := 7
+    e, f := make([]*cas.Agent, n), make([]*cas.Agent, n)
+    names, rev := make([]string, n), make([]string, n)
+    for i := range e {
+        a := cas.MakeAgent(2, r)
+        e[i], f[n - 1 - i] = &a, &a
+        names[i] = a.Encode()
+        rev[n - 1 - i] = names[i]
+    }
+    x := RunTournament(e, names, gp, 2, false, util.MakeRand(1))
+    y := RunTournament(f, rev, gp, 2, false, util.MakeRand(2))
+    wins, losses, ties := 0, 0, 0
+    for k := range x.Standings {
+        u, v := x.Standings[k], y.Standings[k]
+        if u.Name != v.Name || u.Wins != v.Wins || u.Ties != v.Ties || u.Losses != v.Losses || u.MeanScore != v.MeanScore {
+            t.Fatalf("standing %d is %+v one way round and %+v the other", k, u, v)
+        }
+        if u.Games != 2 * (n - 1) || u.Wins + u.Ties + u.Losses != u.Games {
+            t.Fatalf("standing %+v doesn't add up to %d games", u, 2 * (n - 1))
+        }
+        wins, losses, ties = wins + u.Wins, losses + u.Losses, ties + u.Ties
+    }
+    // Every game has a winner and a loser, or two ties:
+    if wins != losses || ties % 2 != 0 {
+        t.Fatalf("the tournament has %d wins, %d losses and %d ties", wins, losses, ties)
+    }
+    for i := 0; i < n; i++ {
+        for j := 0; j < n; j++ {
+            if x.Scores[i][j] != y.Scores[n - 1 - i][n - 1 - j] {
+                t.Fatalf("%s scores %g against %s one way round and %g the other", names[i], x.Scores[i][j], names[j], y.Scores[n - 1 - i][n - 1 - j])
+            }
+        }
+    }
+}
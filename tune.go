@@ -0,0 +1,216 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "math/rand"
+    "os"
+    "sort"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+/* The genome of the meta-GA in TunePdParams(): the parameters of
+   DiscoverPdRule() which are hardest to pick by hand.  */
+type PdTuneParams struct {
+    CohortSize int `json:"cohort_size"`
+    NumRounds int `json:"num_rounds"`
+    ResourceThreshold int `json:"resource_threshold"`
+    MutationFrequency int `json:"mutation_frequency"`
+    GamesPerGen int `json:"games_per_gen"`
+    FitnessGoal int `json:"fitness_goal"`
+}
+
+// The bounds of each gene, in the same order as PdTuneParams.genes():
+var pdTuneBounds = [][2]int{
+    {10, 2000},
+    {10, 1000},
+    {1, 100},
+    {10, 1000000},
+    {1, 100},
+    {50, 100},
+}
+
+func (tp *PdTuneParams) genes() []*int {
+    return []*int{&tp.CohortSize, &tp.NumRounds, &tp.ResourceThreshold,
+                  &tp.MutationFrequency, &tp.GamesPerGen, &tp.FitnessGoal}
+}
+
+// Copies the genome into the parameters for a run:
+func (tp PdTuneParams) apply(p DiscoverPdRuleParams) DiscoverPdRuleParams {
+    p.CohortSize, p.NumRounds, p.ResourceThreshold = tp.CohortSize, tp.NumRounds, tp.ResourceThreshold
+    p.MutationFrequency, p.GamesPerGen, p.FitnessGoal = tp.MutationFrequency, tp.GamesPerGen, tp.FitnessGoal
+    return p
+}
+
+// How a parameter set fared, averaged over every seed it was run with:
+type PdTuneScore struct {
+    Params PdTuneParams `json:"params"`
+    // The mean RuleWinPercent, less Cost times the weight of the cost:
+    Score float64 `json:"score"`
+    WinPercent float64 `json:"win_percent"`
+    // In millions of rounds played, or in seconds, depending on the unit:
+    Cost float64 `json:"cost"`
+    RoundsPlayed float64 `json:"rounds_played"`
+    Seconds float64 `json:"seconds"`
+    GenerationsUsed float64 `json:"generations_used"`
+}
+
+// The settings of the meta-GA itself:
+type PdTuneOptions struct {
+    Generations int
+    Population int
+    // Every parameter set is run once with each of this many seeds:
+    Seeds int
+    // Score points lost per unit of cost, and the unit ("rounds" or "seconds"):
+    CostWeight float64
+    CostUnit string
+}
+
+/* Tunes the parameters of DiscoverPdRule() with an outer genetic
+   algorithm. Its first generation is the parameters in p plus wide
+   mutations of them, so the search starts around the scale asked for.
+   Every generation each parameter set is scored (see PdTuneScore), the
+   best one is kept, and the rest are bred by binary tournaments, uniform
+   crossover and log-normal mutation of the genes. Every parameter set is
+   run with the same seeds, which are derived from the seed in p, so the
+   comparison is fair and, with the "rounds" cost unit, the whole search
+   is reproducible. The rest of p (the generation cap, control sample
+   size, operators and game) is used for every run, so it should be kept
+   small. Returns every parameter set tried, best first.  */
+func TunePdParams(p DiscoverPdRuleParams, opt PdTuneOptions) ([]PdTuneScore, error) {
+    if opt.Generations < 1 || opt.Population < 2 || opt.Seeds < 1 {
+        return nil, fmt.Errorf("meta-GA needs at least 1 generation, 2 parameter sets and 1 seed")
+    }
+    if opt.CostUnit != "rounds" && opt.CostUnit != "seconds" {
+        return nil, fmt.Errorf("unknown cost unit %q (expected rounds or seconds)", opt.CostUnit)
+    }
+    r := util.MakeRand(util.DeriveSeed(p.Seed, -5))
+    seen := map[PdTuneParams]PdTuneScore{}
+    eval := func(tp PdTuneParams) (PdTuneScore, error) {
+        if x, ok := seen[tp]; ok {
+            return x, nil
+        }
+        x := PdTuneScore{Params: tp}
+        q := tp.apply(p)
+        q.Squelch, q.Stats, q.CheckpointFile, q.ClassicGames = true, nil, "", 0
+        for i := 0; i < opt.Seeds; i++ {
+            q.Seed = util.DeriveSeed(p.Seed, -5, i)
+            md, err := DiscoverPdRule(q)
+            if err != nil {
+                return x, err
+            }
+            n := float64(opt.Seeds)
+            x.WinPercent += md.RuleWinPercent / n
+            x.RoundsPlayed += float64(md.RoundsPlayed) / n
+            x.Seconds += md.Seconds / n
+            x.GenerationsUsed += float64(md.GenerationsUsed) / n
+        }
+        x.Cost = x.RoundsPlayed / 1e6
+        if opt.CostUnit == "seconds" {
+            x.Cost = x.Seconds
+        }
+        x.Score = x.WinPercent - opt.CostWeight * x.Cost
+        seen[tp] = x
+        return x, nil
+    }
+
+    // The first generation:
+    pop := []PdTuneParams{{p.CohortSize, p.NumRounds, p.ResourceThreshold, p.MutationFrequency, p.GamesPerGen, p.FitnessGoal}}
+    for len(pop) < opt.Population {
+        tp := pop[0]
+        for i, g := range tp.genes() {
+            *g = pdTuneMutate(*g, pdTuneBounds[i], TUNE_INITIAL_SPREAD, r)
+        }
+        pop = append(pop, tp)
+    }
+
+    for gen := 0; ; gen++ {
+        sc := make([]PdTuneScore, len(pop))
+        for i := range pop {
+            x, err := eval(pop[i])
+            if err != nil {
+                return nil, err
+            }
+            sc[i] = x
+        }
+        sort.SliceStable(sc, func(i, j int) bool {
+            return sc[i].Score > sc[j].Score
+        })
+        if !p.Squelch {
+            fmt.Printf("Meta-generation %d / %d: best score %.02f (%.02f percent, cost %.03f)\n", gen, opt.Generations - 1, sc[0].Score, sc[0].WinPercent, sc[0].Cost)
+        }
+        if gen + 1 >= opt.Generations {
+            break
+        }
+
+        // Keep the best, and breed the rest:
+        pick := func() PdTuneParams {
+            a, b := sc[r.Intn(len(sc))], sc[r.Intn(len(sc))]
+            if b.Score > a.Score {
+                return b.Params
+            }
+            return a.Params
+        }
+        pop = []PdTuneParams{sc[0].Params}
+        for len(pop) < opt.Population {
+            a, b := pick(), pick()
+            ga, gb := a.genes(), b.genes()
+            for i := range ga {
+                if r.Intn(2) == 1 {
+                    *ga[i] = *gb[i]
+                }
+                if r.Intn(len(ga)) == 0 {
+                    *ga[i] = pdTuneMutate(*ga[i], pdTuneBounds[i], TUNE_MUTATION_SPREAD, r)
+                }
+            }
+            pop = append(pop, a)
+        }
+    }
+
+    all := make([]PdTuneScore, 0, len(seen))
+    for _, x := range seen {
+        all = append(all, x)
+    }
+    sort.Slice(all, func(i, j int) bool {
+        if all[i].Score != all[j].Score {
+            return all[i].Score > all[j].Score
+        }
+        // The map is unordered, so ties are broken by the genome:
+        gi, gj := all[i].Params.genes(), all[j].Params.genes()
+        for k := range gi {
+            if *gi[k] != *gj[k] {
+                return *gi[k] < *gj[k]
+            }
+        }
+        return false
+    })
+    return all, nil
+}
+
+/* Scales a gene by a log-normal factor with the given spread, so that big
+   genes take big steps and small genes small ones, making sure it moves
+   by at least 1 and stays within its bounds b.  */
+func pdTuneMutate(x int, b [2]int, spread float64, r *rand.Rand) int {
+    y := int(math.Round(float64(x) * math.Exp(spread * r.NormFloat64())))
+    if y == x {
+        y += 2 * r.Intn(2) - 1
+    }
+    if y < b[0] {
+        y = b[0]
+    }
+    if y > b[1] {
+        y = b[1]
+    }
+    return y
+}
+
+// Writes the scores of the parameter sets out as JSON:
+func SavePdTuneScores(path string, sc []PdTuneScore) error {
+    b, err := json.MarshalIndent(sc, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(path, append(b, '\n'), 0644)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+    "testing"
+
+    "github.com/prisoners_dilemma/util"
+)
+
+func TestPdTuneMutateBounds(t *testing.T) {
+    r := util.MakeRand(17)
+    for _, b := range pdTuneBounds {
+        // Starting genes inside, on and outside the bounds (as the parameters given may be):
+        for _, x := range []int{b[0] - 5, b[0], b[0] + 1, (b[0] + b[1]) / 2, b[1] - 1, b[1], b[1] * 3} {
+            for _, spread := range []float64{0, TUNE_MUTATION_SPREAD, TUNE_INITIAL_SPREAD, 10} {
+                for k := 0; k < 200; k++ {
+                    y := pdTuneMutate(x, b, spread, r)
+                    if y < b[0] || y > b[1] {
+                        t.Fatalf("gene %d with spread %g mutated to %d, outside of %v", x, spread, y, b)
+                    }
+                    // A gene strictly inside the bounds always moves:
+                    if x > b[0] && x < b[1] && y == x {
+                        t.Fatalf("gene %d with spread %g didn't move", x, spread)
+                    }
+                    // Without any spread, it moves by exactly 1:
+                    if spread == 0 && x > b[0] && x < b[1] && y != x - 1 && y != x + 1 {
+                        t.Fatalf("gene %d with no spread mutated to %d", x, y)
+                    }
+                }
+            }
+        }
+    }
+}
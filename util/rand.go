@@ -0,0 +1,45 @@
+package util
+
+import "math/rand"
+
+/* Source is a small splitmix64 generator which satisfies rand.Source64.
+   Its whole state is a single word, so it is cheap to seed one for every
+   goroutine, which is what makes seeded runs reproducible no matter how
+   the goroutines happen to be scheduled.  */
+type Source struct {
+    state uint64
+}
+
+func (s *Source) Seed(seed int64) {
+    s.state = uint64(seed)
+}
+
+func (s *Source) Uint64() uint64 {
+    s.state += 0x9e3779b97f4a7c15
+    z := s.state
+    z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+    z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+    return z ^ (z >> 31)
+}
+
+func (s *Source) Int63() int64 {
+    return int64(s.Uint64() >> 1)
+}
+
+// Returns a PRNG which draws from its own Source:
+func MakeRand(seed int64) *rand.Rand {
+    s := &Source{}
+    s.Seed(seed)
+    return rand.New(s)
+}
+
+/* Derives a new seed from a parent seed and a path of stream numbers
+   (e.g. a generation and then a Cohort member). The same inputs always
+   give the same seed, and different paths give unrelated streams.  */
+func DeriveSeed(seed int64, path ...int) int64 {
+    s := Source{uint64(seed)}
+    for _, k := range path {
+        s.state = s.Uint64() ^ uint64(k)
+    }
+    return int64(s.Uint64())
+}
@@ -0,0 +1,22 @@
+package util
+
+import "testing"
+
+func TestDeriveSeed(t *testing.T) {
+    a, b := MakeRand(DeriveSeed(42, 7, 3)), MakeRand(DeriveSeed(42, 7, 3))
+    for i := 0; i < 1000; i++ {
+        if a.Int63() != b.Int63() {
+            t.Fatalf("streams with the same seed diverged at draw %d", i)
+        }
+    }
+    seen := map[int64]bool{}
+    for i := 0; i < 100; i++ {
+        for j := 0; j < 100; j++ {
+            s := DeriveSeed(42, i, j)
+            if seen[s] {
+                t.Fatalf("derived seed collision at (%d, %d)", i, j)
+            }
+            seen[s] = true
+        }
+    }
+}